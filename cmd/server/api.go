@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/database"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/websocket"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func getGameReplayHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, err := db.GetGameRecord(mux.Vars(r)["id"])
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "game not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"game_id":    record.ID,
+			"player1":    record.Player1Username,
+			"player2":    record.Player2Username,
+			"end_reason": record.EndReason,
+		}
+		if len(record.Seats) > 0 {
+			response["seats"] = record.Seats
+			response["placements"] = record.Placements
+		}
+
+		// A game whose starting position cannot be loaded has nothing to
+		// replay; one that goes wrong later is replayed up to the bad move
+		replay, err := game.VerifyRecord(record)
+		if replay == nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			response["error"] = err.Error()
+		}
+		response["positions"] = replay.Positions
+		response["result"] = replay.Result
+		response["winner"] = replay.Winner
+
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+type notationRequest struct {
+	Moves *string `json:"moves"`
+	FEN   *string `json:"fen"`
+}
+
+// convertNotationHandler converts a move string to a FEN-style position, or
+// validates and normalises a FEN-style position.
+func convertNotationHandler(w http.ResponseWriter, r *http.Request) {
+	var req notationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case req.Moves != nil:
+		moves, err := game.ParseMoveString(*req.Moves)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		board := game.NewBoard()
+		for _, move := range moves {
+			board.MakeMove(move.Column, move.Player)
+		}
+		toMove := 1 + len(moves)%2
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"moves":   game.FormatMoveString(moves),
+			"fen":     game.EncodeFEN(board, toMove),
+			"board":   board.GetGrid(),
+			"to_move": toMove,
+		})
+
+	case req.FEN != nil:
+		board, toMove, err := game.DecodeFEN(*req.FEN)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"fen":     game.EncodeFEN(board, toMove),
+			"board":   board.GetGrid(),
+			"to_move": toMove,
+		})
+
+	default:
+		http.Error(w, "either moves or fen is required", http.StatusBadRequest)
+	}
+}
+
+func exportGameHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, err := db.GetGameRecord(mux.Vars(r)["id"])
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.Error(w, "game not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(record.Seats) > 2 {
+			http.Error(w, "the record format covers two-player games only", http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(game.ExportRecord(record)))
+	}
+}
+
+// requireModerator lets a request through only if it carries the moderator
+// token as a bearer token.
+func requireModerator(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "moderator token required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// getChatLogHandler returns the chat of a game for moderation review, with
+// the original text of any message the word filter changed and the team
+// channels. It is only served to moderators.
+func getChatLogHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		messages, err := db.GetChatLog(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, messages)
+	}
+}
+
+func exportPlayerGamesHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, err := db.GetPlayerGameRecords(mux.Vars(r)["username"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		written := 0
+		for i := range records {
+			// Free-for-all games have no record format
+			if len(records[i].Seats) > 2 {
+				continue
+			}
+			if written > 0 {
+				w.Write([]byte("\n"))
+			}
+			w.Write([]byte(game.ExportRecord(&records[i])))
+			written++
+		}
+	}
+}
+
+func importGamesHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		imported, failures := importGames(db, string(body))
+		status := http.StatusOK
+		if len(imported) == 0 && len(failures) > 0 {
+			status = http.StatusBadRequest
+		}
+
+		writeJSON(w, status, map[string]interface{}{
+			"imported": imported,
+			"errors":   failures,
+		})
+	}
+}
+
+// errorCatalogHandler lists the error codes clients can receive over the
+// websocket, with their default messages.
+func errorCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errors": websocket.ErrorCatalog(),
+	})
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -16,12 +18,17 @@ import (
 	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/websocket"
 	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/database"
 	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/kafka"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 )
 
 func main() {
+	verify := flag.Bool("verify-games", false, "replay every stored game, report mismatches and exit")
+	importFile := flag.String("import-games", "", "load game records from `file` into the database and exit")
+	flag.Parse()
+
 	// Load environment variables
 	godotenv.Load()
 
@@ -33,6 +40,17 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to connect to MongoDB:", err)
 	}
+
+	if *verify {
+		code := verifyGames(db)
+		db.Close()
+		os.Exit(code)
+	}
+	if *importFile != "" {
+		code := importGamesFile(db, *importFile)
+		db.Close()
+		os.Exit(code)
+	}
 	defer db.Close()
 
 	// Initialize Kafka producer
@@ -44,7 +62,7 @@ func main() {
 	defer kafkaProducer.Close()
 
 	// Initialize Kafka consumer
-	kafkaConsumer, err := kafka.NewConsumer(kafkaBrokers, "analytics-group", "game-events")
+	kafkaConsumer, err := kafka.NewConsumer(kafkaBrokers, "analytics-group", "game-events", db)
 	if err != nil {
 		log.Fatal("Failed to create Kafka consumer:", err)
 	}
@@ -58,17 +76,61 @@ func main() {
 	hub := websocket.NewHub()
 	go hub.Run()
 
-	gameManager := game.NewManager()
+	managerConfig := game.DefaultManagerConfig()
+	managerConfig.FinishedRetention = getEnvDuration("GAME_RETENTION", managerConfig.FinishedRetention)
+	managerConfig.WaitingTimeout = getEnvDuration("GAME_WAITING_TIMEOUT", managerConfig.WaitingTimeout)
+	managerConfig.MaxGames = getEnvInt("MAX_GAMES", managerConfig.MaxGames)
+
+	gameManager := game.NewManager(managerConfig, db)
 	matchmaker := matchmaking.NewMatchmaker()
-	wsHandler := websocket.NewHandler(hub, gameManager, matchmaker, db, kafkaProducer)
+	sessions := websocket.NewSessionSigner(getEnv("SESSION_SECRET", ""), 24*time.Hour)
+	handlerConfig := websocket.DefaultHandlerConfig()
+	handlerConfig.MaxSpectators = getEnvInt("MAX_SPECTATORS", handlerConfig.MaxSpectators)
+	handlerConfig.PublicURL = getEnv("PUBLIC_URL", handlerConfig.PublicURL)
+	handlerConfig.ChatRateLimit = getEnvInt("CHAT_RATE_LIMIT", handlerConfig.ChatRateLimit)
+	handlerConfig.ChatRateWindow = getEnvDuration("CHAT_RATE_WINDOW", handlerConfig.ChatRateWindow)
+	handlerConfig.InviteTTL = getEnvDuration("INVITE_TTL", handlerConfig.InviteTTL)
+	handlerConfig.EmoteCooldown = getEnvDuration("EMOTE_COOLDOWN", handlerConfig.EmoteCooldown)
+	handlerConfig.SpectatorChat = getEnv("SPECTATOR_CHAT", "true") != "false"
+	if words := getEnv("CHAT_FILTER", ""); words != "" {
+		handlerConfig.ChatFilter = strings.Split(words, ",")
+	}
+	wsHandler := websocket.NewHandler(handlerConfig, hub, gameManager, matchmaker, db, kafkaProducer, sessions)
+
+	// Bring back games that were in progress when the server last stopped
+	recovered, err := gameManager.Recover()
+	if err != nil {
+		log.Println("Failed to recover games:", err)
+	} else if recovered > 0 {
+		log.Printf("Recovered %d in-progress games", recovered)
+	}
+	go gameManager.Run(ctx)
+	go wsHandler.RunLobby(ctx)
 
 	// Setup HTTP router
 	router := mux.NewRouter()
 	
 	router.HandleFunc("/ws", wsHandler.HandleWebSocket)
 	router.HandleFunc("/api/leaderboard", getLeaderboardHandler(db)).Methods("GET")
+	router.HandleFunc("/api/leaderboard/teams", getTeamLeaderboardHandler(db)).Methods("GET")
+	router.HandleFunc("/api/games/{id}/replay", getGameReplayHandler(db)).Methods("GET")
+	router.HandleFunc("/api/games/{id}/export", exportGameHandler(db)).Methods("GET")
+	router.HandleFunc("/api/players/{username}/export", exportPlayerGamesHandler(db)).Methods("GET")
+	router.HandleFunc("/api/notation/convert", convertNotationHandler).Methods("POST")
+	router.HandleFunc("/api/stats/games", getGameCountsHandler(gameManager)).Methods("GET")
+	router.HandleFunc("/api/stats/openings", getOpeningStatsHandler(db)).Methods("GET")
+	router.HandleFunc("/api/stats/emotes", getEmoteStatsHandler(db)).Methods("GET")
+	router.HandleFunc("/api/errors", errorCatalogHandler).Methods("GET")
 	router.HandleFunc("/api/health", healthCheckHandler).Methods("GET")
 
+	// The chat log holds what the word filter hid and the teams' private
+	// channels, and imports write straight into the games collection, so
+	// both are only served with MODERATOR_TOKEN set and given that token
+	if token := getEnv("MODERATOR_TOKEN", ""); token != "" {
+		router.HandleFunc("/api/games/{id}/chat", requireModerator(token, getChatLogHandler(db))).Methods("GET")
+		router.HandleFunc("/api/games/import", requireModerator(token, importGamesHandler(db))).Methods("POST")
+	}
+
 	// CORS
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
@@ -124,6 +186,78 @@ func getLeaderboardHandler(db *database.DB) http.HandlerFunc {
 	}
 }
 
+func getTeamLeaderboardHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		leaderboard, err := db.GetTeamLeaderboard(10)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(leaderboard)
+	}
+}
+
+func getGameCountsHandler(gameManager *game.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		counts := gameManager.CountByStatus()
+
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"by_status": counts,
+			"total":     total,
+		})
+	}
+}
+
+func getOpeningStatsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := db.GetOpeningStats()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"openings": game.Openings(),
+			"results":  stats,
+		})
+	}
+}
+
+// getEmoteStatsHandler reports how often each emote is used, counting the
+// ones never sent as zero.
+func getEmoteStatsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := db.GetEmoteStats()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		counts := make(map[string]int, len(models.Emotes))
+		for _, emote := range models.Emotes {
+			counts[emote] = 0
+		}
+		for _, entry := range stats {
+			counts[entry.Emote] = entry.Count
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"emotes": models.Emotes,
+			"counts": counts,
+		})
+	}
+}
+
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -134,4 +268,24 @@ func getEnv(key, fallback string) string {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("Invalid value for %s: %q, using %d", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("Invalid value for %s: %q, using %s", key, value, fallback)
+	}
+	return fallback
+}
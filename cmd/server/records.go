@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/database"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/google/uuid"
+)
+
+const maxImportSize = 10 << 20
+
+// importGames parses game records from text and stores every valid one. It
+// returns the IDs of the stored games and a message per rejected record.
+func importGames(db *database.DB, text string) ([]string, []string) {
+	imported := []string{}
+	failures := []string{}
+
+	records, err := game.ParseRecords(text)
+	if err != nil {
+		failures = append(failures, err.Error())
+	}
+
+	for i, parsed := range records {
+		record, err := parsed.GameRecord()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("record %d: %v", i+1, err))
+			continue
+		}
+
+		record.ID = uuid.New().String()
+		if err := db.InsertGameRecord(record); err != nil {
+			failures = append(failures, fmt.Sprintf("record %d: %v", i+1, err))
+			continue
+		}
+		imported = append(imported, record.ID)
+	}
+
+	return imported, failures
+}
+
+// importGamesFile loads game records from a file into the games collection.
+// It returns the process exit code.
+func importGamesFile(db *database.DB, path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Println("Failed to read records:", err)
+		return 1
+	}
+
+	imported, failures := importGames(db, string(data))
+	for _, failure := range failures {
+		log.Println(failure)
+	}
+
+	log.Printf("Imported %d games, rejected %d", len(imported), len(failures))
+	if len(failures) > 0 {
+		return 1
+	}
+	return 0
+}
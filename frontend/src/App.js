@@ -1,11 +1,59 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState, useEffect, useRef } from 'react';
 import useWebSocket from './hooks/useWebSocket';
 import GameBoard from './components/GameBoard';
 import Leaderboard from './components/Leaderboard';
+import LiveGames from './components/LiveGames';
+import ChatPanel from './components/ChatPanel';
 import ThemeSelector from './components/ThemeSelector';
 import { useTheme } from './contexts/ThemeContext';
 import './App.css';
 
+const EMOTES = {
+  good_move: '👍 Good move',
+  oops: '😅 Oops',
+  gg: '🤝 GG',
+  thinking: '🤔 Thinking...',
+};
+
+// Compact game events carry what they changed rather than the whole game.
+// applyState brings a game up to date with the turn, deadline and status
+// that every one of them carries.
+const applyState = (game, state) =>
+  game && {
+    ...game,
+    status: state.status,
+    current_turn: state.current_turn,
+    turn_seat: state.turn_seat,
+    turn_deadline: state.turn_deadline,
+    takeback_request: state.takeback_request || 0,
+  };
+
+// applyMove applies a compact move, which carries the cells it changed.
+const applyMove = (game, move) => {
+  if (!game) return game;
+  const board = game.board.map((row) => [...row]);
+  move.changes.forEach(({ row, column, value }) => {
+    board[row][column] = value;
+  });
+  return {
+    ...applyState(game, move),
+    board,
+    moves: [...game.moves, { column: move.column, row: move.row, player: move.player, type: move.powerup }],
+    inventory: move.inventory || game.inventory,
+  };
+};
+
+// applyTakeback empties the cells of the moves a takeback removed.
+const applyTakeback = (game, takeback) => {
+  if (!game) return game;
+  const board = game.board.map((row) => [...row]);
+  const undone = takeback.undone || [];
+  undone.forEach(({ row, column }) => {
+    board[row][column] = 0;
+  });
+  return { ...applyState(game, takeback), board, moves: [...game.moves, ...undone] };
+};
+
 function App() {
   const [username, setUsername] = useState('');
   const [inputUsername, setInputUsername] = useState('');
@@ -14,10 +62,56 @@ function App() {
   const [message, setMessage] = useState('');
   const [refreshLeaderboard, setRefreshLeaderboard] = useState(0);
   const [matchmakingTime, setMatchmakingTime] = useState(0);
+  const [spectating, setSpectating] = useState(false);
+  const [spectators, setSpectators] = useState(0);
+  const [shareLink, setShareLink] = useState('');
+  const [lobbyGames, setLobbyGames] = useState([]);
+  const [lobbyCounts, setLobbyCounts] = useState({ online: 0, queued: 0 });
+  const [chatMessages, setChatMessages] = useState([]);
+  const [muted, setMuted] = useState([]);
+  const [lastEmote, setLastEmote] = useState(null);
+  const [rematchOffer, setRematchOffer] = useState(null);
+  const [headToHead, setHeadToHead] = useState(null);
+  const [invite, setInvite] = useState(null);
+  const [inviteCode, setInviteCode] = useState('');
+  const [showEmotes, setShowEmotes] = useState(localStorage.getItem('showEmotes') !== 'false');
 
   const { messages, isConnected, sendMessage } = useWebSocket(username);
+  // The game as last rendered, for events that only carry what changed
+  const gameRef = useRef(null);
+  useEffect(() => {
+    gameRef.current = gameState;
+  }, [gameState]);
   const { theme } = useTheme();
 
+  // Opening a share link watches that game once connected
+  useEffect(() => {
+    if (isConnected && window.location.pathname.startsWith('/watch/')) {
+      sendMessage({ type: 'spectate', link: window.location.href });
+    }
+    if (isConnected && window.location.pathname.startsWith('/join/')) {
+      sendMessage({ type: 'join_private', code: window.location.href });
+    }
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [isConnected]);
+
+  // Emotes are on by default; the choice is remembered across visits
+  useEffect(() => {
+    localStorage.setItem('showEmotes', String(showEmotes));
+    if (isConnected) {
+      sendMessage({ type: 'set_emotes', enabled: showEmotes });
+    }
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [isConnected, showEmotes]);
+
+  // The lobby feed runs while on the home screen
+  useEffect(() => {
+    if (!isConnected || status !== 'idle') return undefined;
+    sendMessage({ type: 'subscribe_lobby' });
+    return () => sendMessage({ type: 'unsubscribe_lobby' });
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [isConnected, status]);
+
   // Matchmaking timer
   useEffect(() => {
     let interval;
@@ -55,8 +149,17 @@ function App() {
 
     switch (lastMessage.type) {
       case 'game_start':
+        sessionStorage.setItem('resumeToken', lastMessage.resume_token);
+        sessionStorage.setItem('lastMove', '0');
         setGameState(lastMessage.game);
         setStatus('playing');
+        setSpectating(false);
+        setSpectators(0);
+        setInvite(null);
+        setShareLink(lastMessage.share_link);
+        setChatMessages([]);
+        setRematchOffer(null);
+        setHeadToHead(lastMessage.head_to_head || null);
         setMessage(
           lastMessage.game.is_bot
             ? ' Playing against Bot'
@@ -65,22 +168,161 @@ function App() {
         break;
 
       case 'move_made':
+      case 'powerup_used':
+        sessionStorage.setItem('lastMove', String(lastMessage.moves));
+        setGameState((prev) => applyMove(prev, lastMessage));
+        break;
+
+      case 'spectate_start':
         setGameState(lastMessage.game);
+        setStatus('playing');
+        setSpectating(true);
+        setSpectators(lastMessage.spectators);
+        setShareLink(lastMessage.share_link);
+        setChatMessages([]);
+        setMessage(' Spectating');
         break;
 
-      case 'game_end':
+      case 'spectator_count':
+        setSpectators(lastMessage.spectators);
+        break;
+
+      case 'lobby_snapshot':
+        setLobbyGames(lastMessage.games);
+        setLobbyCounts({ online: lastMessage.online, queued: lastMessage.queued });
+        break;
+
+      // An added game may already be in the snapshot, so both replace by ID
+      case 'lobby_game_added':
+      case 'lobby_game_updated':
+        setLobbyGames((games) => [
+          ...games.filter((g) => g.id !== lastMessage.game.id),
+          lastMessage.game,
+        ]);
+        break;
+
+      case 'lobby_game_removed':
+        setLobbyGames((games) => games.filter((g) => g.id !== lastMessage.game_id));
+        break;
+
+      case 'lobby_counts':
+        setLobbyCounts({ online: lastMessage.online, queued: lastMessage.queued });
+        break;
+
+      case 'team_chat':
+        setChatMessages((prev) => [...prev, { ...lastMessage, channel: 'team' }]);
+        break;
+
+      case 'chat':
+        setChatMessages((prev) => [...prev, lastMessage]);
+        break;
+
+      case 'rematch_offered':
+        setRematchOffer(lastMessage.from);
+        break;
+
+      case 'private_created':
+        setInvite(lastMessage);
+        break;
+
+      case 'rematch_declined':
+        setMessage(` ${lastMessage.from} declined the rematch`);
+        break;
+
+      case 'emote':
+        setLastEmote(`${lastMessage.from}: ${EMOTES[lastMessage.emote] || lastMessage.emote}`);
+        break;
+
+      case 'chat_settings':
+        setMuted([...lastMessage.muted, ...lastMessage.blocked]);
+        break;
+
+      case 'takeback_requested':
+      case 'takeback_declined':
+        setGameState((prev) => applyState(prev, lastMessage));
+        break;
+
+      case 'takeback':
+        sessionStorage.setItem('lastMove', String(lastMessage.moves));
+        setGameState((prev) => applyTakeback(prev, lastMessage));
+        setMessage(' Move taken back');
+        break;
+
+      case 'player_eliminated': {
+        sessionStorage.setItem('lastMove', String(lastMessage.moves));
+        setGameState((prev) => prev && { ...applyState(prev, lastMessage), placements: lastMessage.placements });
+        const out = (lastMessage.placements || []).find((p) => p.seat === lastMessage.seat);
+        setMessage(` ${out ? out.username : 'A player'} is out`);
+        break;
+      }
+
+      case 'swap':
+        sessionStorage.setItem('lastMove', String(lastMessage.moves));
+        setGameState((prev) => prev && {
+          ...applyState(prev, lastMessage),
+          player1: lastMessage.player1,
+          player2: lastMessage.player2,
+        });
+        setMessage(' Seats swapped');
+        break;
+
+      case 'rejoin_success':
+        sessionStorage.setItem('resumeToken', lastMessage.resume_token);
+        // Without a game, the events we missed are replayed instead
+        if (lastMessage.game) {
+          sessionStorage.setItem('lastMove', String(lastMessage.game.moves.length));
+          setGameState(lastMessage.game);
+          setStatus(lastMessage.game.status === 'finished' ? 'finished' : 'playing');
+        }
+        setSpectators(lastMessage.spectators);
+        setMessage(' Reconnected');
+        break;
+
+      case 'resync_state':
+        if (!spectating) {
+          sessionStorage.setItem('lastMove', String(lastMessage.game.moves.length));
+        }
         setGameState(lastMessage.game);
+        setStatus(lastMessage.game.status === 'finished' ? 'finished' : 'playing');
+        setSpectators(lastMessage.spectators);
+        break;
+
+      case 'game_end':
+        sessionStorage.removeItem('resumeToken');
+        sessionStorage.removeItem('lastMove');
+        setGameState((prev) => prev && {
+          ...applyState(prev, lastMessage),
+          winner: lastMessage.winner,
+          end_reason: lastMessage.end_reason,
+          winning_team: lastMessage.winning_team,
+          placements: lastMessage.placements,
+          finished_at: lastMessage.finished_at,
+        });
         setStatus('finished');
         setRefreshLeaderboard(prev => prev + 1);
         
         if (lastMessage.result === 'draw') {
           setMessage(" It's a Draw!");
+        } else if (spectating) {
+          setMessage(lastMessage.winner ? ` ${lastMessage.winner.username} wins` : ' Game over');
+        } else if (lastMessage.winning_team && gameRef.current) {
+          const mySeat = gameRef.current.seats.findIndex((p) => p.username === username);
+          setMessage(mySeat % 2 === lastMessage.winning_team - 1 ? ' Victory!' : ' Try Again!');
         } else if (lastMessage.winner) {
           const isWinner = lastMessage.winner.username === username;
           setMessage(isWinner ? ' Victory!' : ' Try Again!');
         }
         break;
 
+      case 'match_end': {
+        const mine = lastMessage.scores[username] || 0;
+        const theirs = Object.entries(lastMessage.scores)
+          .filter(([name]) => name !== username)
+          .reduce((sum, [, score]) => sum + score, 0);
+        setMessage(` Match over: ${mine} - ${theirs}`);
+        break;
+      }
+
       case 'error':
         setMessage(`❌ ${lastMessage.message}`);
         break;
@@ -88,7 +330,7 @@ function App() {
       default:
         break;
     }
-  }, [messages, username]);
+  }, [messages, username, spectating]);
 
   const handleLogin = (e) => {
     e.preventDefault();
@@ -108,9 +350,27 @@ function App() {
     sendMessage({ type: 'find_match' });
   };
 
+  // Free-for-all games list every player in seat order
+  const seatOf = (game) => {
+    if (game.seats) {
+      return game.seats.findIndex((p) => p.username === username) + 1;
+    }
+    return username === game.player1.username ? 1 : 2;
+  };
+
+  // Team games track the teammate to move separately from the colour
+  const turnSeat = (game) => (game.settings.teams ? game.turn_seat : game.current_turn);
+
+  const playerOnTurn = (game) => {
+    if (game.seats) {
+      return game.seats[turnSeat(game) - 1];
+    }
+    return game.current_turn === game.player1.piece ? game.player1 : game.player2;
+  };
+
   const handleColumnClick = (col) => {
-    if (status !== 'playing') return;
-    if (gameState.current_turn !== (username === gameState.player1.username ? 1 : 2)) {
+    if (status !== 'playing' || spectating) return;
+    if (turnSeat(gameState) !== seatOf(gameState)) {
       return;
     }
     sendMessage({
@@ -119,7 +379,54 @@ function App() {
     });
   };
 
+  const handleCreatePrivate = () => {
+    sendMessage({ type: 'create_private' });
+  };
+
+  const handleCancelPrivate = () => {
+    sendMessage({ type: 'cancel_private' });
+    setInvite(null);
+  };
+
+  const handleJoinPrivate = (e) => {
+    e.preventDefault();
+    if (inviteCode.trim()) {
+      sendMessage({ type: 'join_private', code: inviteCode.trim() });
+      setInviteCode('');
+    }
+  };
+
+  const handleSendChat = (text) => {
+    sendMessage({ type: 'chat', text });
+  };
+
+  const handleOfferRematch = () => {
+    sendMessage({ type: 'offer_rematch' });
+    setMessage(' Rematch offered');
+  };
+
+  const handleAnswerRematch = (accept) => {
+    sendMessage({ type: accept ? 'accept_rematch' : 'decline_rematch' });
+    setRematchOffer(null);
+  };
+
+  const handleEmote = (emote) => {
+    sendMessage({ type: 'emote', emote });
+  };
+
+  const handleMute = (player, mute) => {
+    sendMessage({ type: mute ? 'mute' : 'unmute', username: player });
+  };
+
+  const handleWatch = (gameId) => {
+    sendMessage({ type: 'spectate', game_id: gameId });
+  };
+
   const handlePlayAgain = () => {
+    if (spectating) {
+      sendMessage({ type: 'stop_spectating' });
+      setSpectating(false);
+    }
     setGameState(null);
     setStatus('idle');
     setMessage('');
@@ -256,6 +563,29 @@ function App() {
                   FIND MATCH
                 </button>
                 <p className="hint-text">Ready to play? Click to start!</p>
+                {invite ? (
+                  <div className="private-invite">
+                    <p>Invite code: <strong>{invite.code}</strong></p>
+                    <input type="text" readOnly value={invite.link} onFocus={(e) => e.target.select()} />
+                    <button onClick={handleCancelPrivate} className="btn btn-secondary">
+                      CANCEL INVITE
+                    </button>
+                  </div>
+                ) : (
+                  <button onClick={handleCreatePrivate} className="btn btn-secondary">
+                    PRIVATE GAME
+                  </button>
+                )}
+                <form onSubmit={handleJoinPrivate} className="join-private">
+                  <input
+                    type="text"
+                    placeholder="Invite code"
+                    value={inviteCode}
+                    onChange={(e) => setInviteCode(e.target.value)}
+                  />
+                  <button type="submit" className="btn btn-secondary">JOIN</button>
+                </form>
+                <LiveGames games={lobbyGames} counts={lobbyCounts} onWatch={handleWatch} />
               </div>
             )}
 
@@ -276,11 +606,7 @@ function App() {
                     <div className="turn-display">
                       <div className={`turn-indicator ${gameState.current_turn === 1 ? 'player1' : 'player2'}`}>
                         <div className="pulse-dot"></div>
-                        <span>
-                          {gameState.current_turn === gameState.player1.piece
-                            ? `${gameState.player1.username}'s Turn`
-                            : `${gameState.player2.username}'s Turn`}
-                        </span>
+                        <span>{`${playerOnTurn(gameState).username}'s Turn`}</span>
                       </div>
                     </div>
                   )}
@@ -289,13 +615,50 @@ function App() {
                 <GameBoard
                   board={gameState.board}
                   onColumnClick={handleColumnClick}
-                  currentTurn={gameState.current_turn}
-                  myPiece={username === gameState.player1.username ? 1 : 2}
+                  currentTurn={turnSeat(gameState)}
+                  myPiece={spectating ? 0 : seatOf(gameState)}
                   gameStatus={status}
+                  cube={gameState.settings.ruleset === '3d'}
                 />
 
+                {showEmotes && lastEmote && <p className="emote-note">{lastEmote}</p>}
+                {!spectating && (
+                  <div className="emote-bar">
+                    {Object.entries(EMOTES).map(([emote, label]) => (
+                      <button key={emote} onClick={() => handleEmote(emote)} className="btn btn-emote">
+                        {label}
+                      </button>
+                    ))}
+                    <label className="emote-toggle">
+                      <input
+                        type="checkbox"
+                        checked={showEmotes}
+                        onChange={(e) => setShowEmotes(e.target.checked)}
+                      />
+                      Show emotes
+                    </label>
+                  </div>
+                )}
+
+                {status === 'finished' && rematchOffer && (
+                  <div className="rematch-offer">
+                    <p>{rematchOffer} wants a rematch</p>
+                    <button onClick={() => handleAnswerRematch(true)} className="btn btn-primary">
+                      ACCEPT
+                    </button>
+                    <button onClick={() => handleAnswerRematch(false)} className="btn btn-secondary">
+                      DECLINE
+                    </button>
+                  </div>
+                )}
+
                 {status === 'finished' && (
                   <div className="game-end-actions">
+                    {!spectating && !gameState.seats && (
+                      <button onClick={handleOfferRematch} className="btn btn-primary btn-large">
+                         REMATCH
+                      </button>
+                    )}
                     <button onClick={handlePlayAgain} className="btn btn-primary btn-glow btn-large">
                        PLAY AGAIN
                     </button>
@@ -331,8 +694,31 @@ function App() {
                       </p>
                     </div>
                   </div>
+                  {headToHead && headToHead.games > 0 && (
+                    <p className="head-to-head">
+                      Head to head: {gameState.player1.username} {headToHead.wins[gameState.player1.username]}
+                      {' - '}
+                      {headToHead.wins[gameState.player2.username]} {gameState.player2.username}
+                      {headToHead.draws > 0 && ` (${headToHead.draws} drawn)`}
+                    </p>
+                  )}
+                  <p className="spectator-count">👁 {spectators} watching</p>
+                  {shareLink && (
+                    <p className="share-link">Share: <code>{shareLink}</code></p>
+                  )}
                 </div>
               )}
+
+              {gameState && (status === 'playing' || status === 'finished') && (
+                <ChatPanel
+                  messages={chatMessages}
+                  username={username}
+                  spectating={spectating}
+                  muted={muted}
+                  onSend={handleSendChat}
+                  onMute={handleMute}
+                />
+              )}
               
               {(status === 'idle' || status === 'searching') && (
                 <div className="game-rules">
@@ -0,0 +1,47 @@
+import React, { useState } from 'react';
+import './ChatPanel.css';
+
+const MAX_LENGTH = 500;
+
+const ChatPanel = ({ messages, username, spectating, muted = [], onSend, onMute }) => {
+  const [text, setText] = useState('');
+
+  const handleSubmit = (e) => {
+    e.preventDefault();
+    if (text.trim()) {
+      onSend(text.trim());
+      setText('');
+    }
+  };
+
+  return (
+    <div className="chat-panel">
+      <h4>{spectating ? 'SPECTATOR CHAT' : 'CHAT'}</h4>
+      <ul className="chat-messages">
+        {messages.map((msg, i) => (
+          <li key={i} className={`chat-message chat-${msg.channel}`}>
+            <span className="chat-from">{msg.from}</span>
+            {msg.channel !== 'players' && <small> ({msg.channel})</small>}
+            : {msg.text}
+            {msg.from !== username && (
+              <button className="chat-mute" onClick={() => onMute(msg.from, !muted.includes(msg.from))}>
+                {muted.includes(msg.from) ? 'unmute' : 'mute'}
+              </button>
+            )}
+          </li>
+        ))}
+      </ul>
+      <form onSubmit={handleSubmit} className="chat-form">
+        <input
+          type="text"
+          value={text}
+          onChange={(e) => setText(e.target.value)}
+          maxLength={MAX_LENGTH}
+          placeholder="Say something..."
+        />
+      </form>
+    </div>
+  );
+};
+
+export default ChatPanel;
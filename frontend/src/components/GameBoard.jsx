@@ -2,7 +2,9 @@ import React from 'react';
 import { useTheme } from '../contexts/ThemeContext';
 import './GameBoard.css';
 
-const GameBoard = ({ board, onColumnClick, currentTurn, myPiece, gameStatus }) => {
+const CUBE_SIZE = 4;
+
+const GameBoard = ({ board, onColumnClick, currentTurn, myPiece, gameStatus, cube }) => {
   const { theme } = useTheme();
   
   const handleCellClick = (rowIndex, colIndex) => {
@@ -21,11 +23,48 @@ const GameBoard = ({ board, onColumnClick, currentTurn, myPiece, gameStatus }) =
   const getPieceColor = (piece) => {
     if (piece === 1) return 'red';
     if (piece === 2) return 'yellow';
+    if (piece === 3) return 'green';
+    if (piece === 4) return 'blue';
+    if (piece === -1) return 'wall';
     return 'empty';
   };
 
   const isMyTurn = currentTurn === myPiece && gameStatus === 'playing';
 
+  // A 3D board has one row per height and one column per peg; show each
+  // height as its own 4x4 layer, bottom layer first, and drop onto the peg
+  if (cube) {
+    const layers = board ? board.map((_, level) => board.length - 1 - level) : [];
+    return (
+      <div className={`game-board-container ${isMyTurn ? 'my-turn' : ''}`}>
+        <div className="game-board cube-board">
+          {layers.map((rowIndex) => (
+            <div key={rowIndex} className="cube-layer">
+              <div className="cube-layer-label">Level {board.length - rowIndex}</div>
+              {Array.from({ length: CUBE_SIZE }, (_, y) => (
+                <div key={y} className="board-row">
+                  {Array.from({ length: CUBE_SIZE }, (_, x) => {
+                    const colIndex = y * CUBE_SIZE + x;
+                    const cell = board[rowIndex][colIndex];
+                    return (
+                      <div
+                        key={`${rowIndex}-${colIndex}`}
+                        className={`board-cell ${getPieceColor(cell)} ${isMyTurn ? 'clickable' : ''}`}
+                        onClick={() => handleCellClick(rowIndex, colIndex)}
+                      >
+                        <div className="piece"></div>
+                      </div>
+                    );
+                  })}
+                </div>
+              ))}
+            </div>
+          ))}
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className={`game-board-container ${isMyTurn ? 'my-turn' : ''}`}>
       <div className="game-board">
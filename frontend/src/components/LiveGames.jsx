@@ -0,0 +1,45 @@
+import React from 'react';
+import './LiveGames.css';
+
+const record = (player) => `${player.wins}-${player.losses}-${player.draws}`;
+
+const LiveGames = ({ games = [], counts, onWatch }) => {
+  const sorted = [...games].sort((a, b) => new Date(a.started_at) - new Date(b.started_at));
+
+  return (
+    <div className="live-games">
+      <h2>📺 LIVE GAMES</h2>
+      <p className="lobby-counts">
+        {counts.online} online • {counts.queued} searching
+      </p>
+      {sorted.length === 0 ? (
+        <p className="empty-message">No games being played right now</p>
+      ) : (
+        <ul>
+          {sorted.map((game) => (
+            <li key={game.id} className="live-game">
+              <div className="live-game-players">
+                {game.players.map((player) => (
+                  <span key={player.seat} className="live-game-player">
+                    {player.username}
+                    <small> ({record(player)})</small>
+                  </span>
+                ))}
+              </div>
+              <div className="live-game-meta">
+                <span>{game.variant}</span>
+                <span>{game.moves} moves</span>
+                <span>👁 {game.spectators}</span>
+              </div>
+              <button onClick={() => onWatch(game.id)} className="btn btn-secondary">
+                WATCH
+              </button>
+            </li>
+          ))}
+        </ul>
+      )}
+    </div>
+  );
+};
+
+export default LiveGames;
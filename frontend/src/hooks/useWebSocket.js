@@ -1,9 +1,49 @@
 import { useEffect, useRef, useState } from 'react';
 
+// The server speaks a versioned protocol picked by subprotocol. Version 2
+// wraps each message in an envelope; the rest of the app works with flat
+// messages, so they are wrapped and unwrapped here.
+const PROTOCOL_V2 = 'fourinarow.v2';
+
+const wrap = ({ type, ...payload }, id) => ({ type, version: 2, id: String(id), payload });
+
+const unwrap = (envelope) => ({ ...envelope.payload, type: envelope.type, id: envelope.id, seq: envelope.seq });
+
+const encode = (socket, message, id) =>
+  JSON.stringify(socket.protocol === PROTOCOL_V2 ? wrap(message, id) : message);
+
+// Game events carry a sequence number, and these messages say which event
+// the game they bring is at.
+const SEQ_BASELINES = ['game_start', 'spectate_start', 'rejoin_success', 'resync_state'];
+
+const RECONNECT_DELAY = 2000;
+
+// We ask for compact messages: the whole game only comes with the messages
+// above, with its board as a string, one character per cell with rows split
+// by '/'. Other game events carry only what they changed.
+const decodeBoard = (board) =>
+  board.split('/').map((row) =>
+    Array.from(row, (cell) => {
+      if (cell === '.') return 0;
+      if (cell === '#') return -1;
+      return Number(cell);
+    })
+  );
+
+const expand = (message) =>
+  message.game && typeof message.game.board === 'string'
+    ? { ...message, game: { ...message.game, board: decodeBoard(message.game.board) } }
+    : message;
+
 const useWebSocket = (username) => {
   const [messages, setMessages] = useState([]);
   const [isConnected, setIsConnected] = useState(false);
   const ws = useRef(null);
+  const nextId = useRef(1);
+  // lastSeq is the last game event applied; resyncing is set while waiting
+  // for the whole game after a gap
+  const lastSeq = useRef(0);
+  const resyncing = useRef(false);
 
   useEffect(() => {
     // Clear messages when username changes or is cleared
@@ -20,36 +60,88 @@ const useWebSocket = (username) => {
     }
 
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws?username=${encodeURIComponent(username)}`;
-    
-    // Create new WebSocket connection
-    ws.current = new WebSocket(wsUrl);
+    const wsUrl = `${protocol}//${window.location.host}/ws?username=${encodeURIComponent(username)}&compact=true`;
+    let reconnectTimer = null;
 
-    ws.current.onopen = () => {
-      console.log('WebSocket Connected');
-      setIsConnected(true);
+    const send = (message) => {
+      ws.current.send(encode(ws.current, message, nextId.current++));
     };
 
-    ws.current.onmessage = (event) => {
-      const message = JSON.parse(event.data);
-      setMessages((prev) => [...prev, message]);
+    // Drops events already applied and asks for the whole game on a gap.
+    // Returns whether the message should be passed on.
+    const sequence = (message) => {
+      if (SEQ_BASELINES.includes(message.type)) {
+        lastSeq.current = message.seq || 0;
+        resyncing.current = false;
+        return true;
+      }
+      if (!message.seq) return true;
+      if (resyncing.current || message.seq <= lastSeq.current) return false;
+      if (message.seq > lastSeq.current + 1) {
+        resyncing.current = true;
+        send({ type: 'resync' });
+        return false;
+      }
+      lastSeq.current = message.seq;
+      send({ type: 'ack', seq: message.seq });
+      return true;
     };
 
-    ws.current.onerror = (error) => {
-      console.error('WebSocket Error:', error);
-    };
+    const connect = () => {
+      // Create new WebSocket connection
+      ws.current = new WebSocket(wsUrl, [PROTOCOL_V2]);
 
-    ws.current.onclose = () => {
-      console.log('WebSocket Disconnected');
-      setIsConnected(false);
+      ws.current.onopen = () => {
+        console.log('WebSocket Connected');
+        setIsConnected(true);
+
+        // Reclaim our seat if we dropped out of a running game. Within the
+        // same page the server can replay just what we missed.
+        const resumeToken = sessionStorage.getItem('resumeToken');
+        if (resumeToken) {
+          send({
+            type: 'rejoin',
+            resume_token: resumeToken,
+            last_move: Number(sessionStorage.getItem('lastMove') || 0),
+            last_seq: lastSeq.current || undefined,
+          });
+        }
+      };
+
+      ws.current.onmessage = (event) => {
+        const data = JSON.parse(event.data);
+        const message = expand(ws.current && ws.current.protocol === PROTOCOL_V2 ? unwrap(data) : data);
+        if (sequence(message)) {
+          setMessages((prev) => [...prev, message]);
+        }
+      };
+
+      ws.current.onerror = (error) => {
+        console.error('WebSocket Error:', error);
+      };
+
+      ws.current.onclose = () => {
+        console.log('WebSocket Disconnected');
+        setIsConnected(false);
+        // The server closes connections that fall behind; come back and
+        // pick up where we were
+        if (ws.current) {
+          reconnectTimer = setTimeout(connect, RECONNECT_DELAY);
+        }
+      };
     };
 
+    connect();
+
     // Cleanup function
     return () => {
+      clearTimeout(reconnectTimer);
       if (ws.current) {
-        ws.current.close();
+        const socket = ws.current;
         ws.current = null;
+        socket.close();
       }
+      lastSeq.current = 0;
       setMessages([]);
       setIsConnected(false);
     };
@@ -57,7 +149,7 @@ const useWebSocket = (username) => {
 
   const sendMessage = (message) => {
     if (ws.current && ws.current.readyState === WebSocket.OPEN) {
-      ws.current.send(JSON.stringify(message));
+      ws.current.send(encode(ws.current, message, nextId.current++));
     } else {
       console.error('WebSocket is not connected');
     }
@@ -5,17 +5,37 @@ import (
 	"time"
 
 	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
 )
 
 type Bot struct {
 	playerNum int
+	// mistakes is the percentage of moves played at random.
+	mistakes int
+	// careful makes the bot avoid moves that let the opponent win on top.
+	careful bool
 }
 
 func NewBot(playerNum int) *Bot {
-	return &Bot{playerNum: playerNum}
+	return NewBotWithLevel(playerNum, models.BotMedium)
 }
 
-// GetMove returns the best move for the bot with intentional mistakes for balance
+// NewBotWithLevel returns a bot of the given difficulty. An unknown or empty
+// level plays as medium.
+func NewBotWithLevel(playerNum int, level string) *Bot {
+	switch level {
+	case models.BotEasy:
+		return &Bot{playerNum: playerNum, mistakes: 35}
+	case models.BotHard:
+		return &Bot{playerNum: playerNum, careful: true}
+	default:
+		return &Bot{playerNum: playerNum, mistakes: 10}
+	}
+}
+
+// GetMove returns the best move for the bot with intentional mistakes for balance.
+// The bot only plays plain pieces; in an arcade game it never uses its
+// power-ups and treats walls as cells no one owns.
 func (b *Bot) GetMove(board *game.Board) int {
 	// Add 2-second delay before bot makes a move
 	time.Sleep(2 * time.Second)
@@ -30,8 +50,8 @@ func (b *Bot) GetMove(board *game.Board) int {
 		opponent = 2
 	}
 
-	// 90% chance to play optimally (makes bot beatable)
-	playOptimally := rand.Intn(100) < 90
+	// Deliberate mistakes make the bot beatable
+	playOptimally := rand.Intn(100) >= b.mistakes
 
 	if playOptimally {
 		// Priority 1: Win if possible
@@ -48,7 +68,14 @@ func (b *Bot) GetMove(board *game.Board) int {
 			}
 		}
 
+		if b.careful {
+			availableCols = b.safeColumns(board, availableCols, opponent)
+		}
+
 		// Priority 3: Look for potential winning setups (two in a row)
+		if board.IsCube() {
+			return b.findBestCubeMove(board, availableCols, opponent)
+		}
 		bestCol := b.findBestSetup(board, availableCols)
 		if bestCol != -1 {
 			return bestCol
@@ -65,10 +92,26 @@ func (b *Bot) GetMove(board *game.Board) int {
 		}
 	}
 
-	// 10% chance: Make a random move (intentional mistake)
+	// Otherwise make a random move (intentional mistake)
 	return availableCols[rand.Intn(len(availableCols))]
 }
 
+// ShouldSwap decides whether the bot, as seat 2 in a swap-rule game, takes
+// over the opening move. An opening piece in one of the three middle
+// columns is strong enough to be worth taking. Only player 1's piece
+// counts: a wall or any other neutral cell on the bottom row is not an
+// opening.
+func (b *Bot) ShouldSwap(board *game.Board) bool {
+	grid := board.GetGrid()
+	bottom := grid[len(grid)-1]
+	for _, col := range []int{2, 3, 4} {
+		if bottom[col] == 1 {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *Bot) isWinningMove(board *game.Board, col int, player int) bool {
 	clonedBoard := board.Clone()
 	row, ok := clonedBoard.MakeMove(col, player)
@@ -78,6 +121,25 @@ func (b *Bot) isWinningMove(board *game.Board, col int, player int) bool {
 	return clonedBoard.CheckWin(row, col, player)
 }
 
+// safeColumns leaves out the columns where the bot's piece would let the
+// opponent win with the next piece. If every column does, all are returned.
+func (b *Bot) safeColumns(board *game.Board, availableCols []int, opponent int) []int {
+	var safe []int
+	for _, col := range availableCols {
+		clonedBoard := board.Clone()
+		if _, ok := clonedBoard.MakeMove(col, b.playerNum); !ok {
+			continue
+		}
+		if !b.isWinningMove(clonedBoard, col, opponent) {
+			safe = append(safe, col)
+		}
+	}
+	if len(safe) == 0 {
+		return availableCols
+	}
+	return safe
+}
+
 // findBestSetup looks for moves that create two-in-a-row opportunities
 func (b *Bot) findBestSetup(board *game.Board, availableCols []int) int {
 	bestScore := -1
@@ -100,6 +162,60 @@ func (b *Bot) findBestSetup(board *game.Board, availableCols []int) int {
 	return bestCol
 }
 
+// findBestCubeMove picks a move on the 3D board by the lines through the
+// landing cell, avoiding moves that let the opponent win on top of them.
+func (b *Bot) findBestCubeMove(board *game.Board, availableCols []int, opponent int) int {
+	bestScore := 0
+	bestCol := availableCols[0]
+
+	for i, col := range availableCols {
+		clonedBoard := board.Clone()
+		row, ok := clonedBoard.MakeMove(col, b.playerNum)
+		if !ok {
+			continue
+		}
+
+		score := b.evaluateCubeCell(clonedBoard, row, col, opponent)
+		if b.isWinningMove(clonedBoard, col, opponent) {
+			score -= 1000
+		}
+		if i == 0 || score > bestScore {
+			bestScore = score
+			bestCol = col
+		}
+	}
+
+	return bestCol
+}
+
+// evaluateCubeCell scores a piece on the 3D board by the lines through it
+// that are still open to the bot, counting more for pieces already there. A
+// line that would also block the opponent's three scores as well.
+func (b *Bot) evaluateCubeCell(board *game.Board, row, col, opponent int) int {
+	score := 0
+	grid := board.GetGrid()
+
+	for _, line := range game.CubeLinesThrough(row, col) {
+		own, theirs := 0, 0
+		for _, cell := range line {
+			switch grid[cell.Row][cell.Col] {
+			case b.playerNum:
+				own++
+			case opponent:
+				theirs++
+			}
+		}
+		switch {
+		case theirs == 0:
+			score += own * own * 10
+		case own == 1 && theirs == 2:
+			score += 15
+		}
+	}
+
+	return score
+}
+
 // evaluatePosition gives a score to a position based on potential winning lines
 func (b *Bot) evaluatePosition(board *game.Board, row, col, player int) int {
 	score := 0
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func TestShouldSwap(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		// wall, if not 0, is the 1-indexed column player 1 walls off
+		// in an arcade game instead.
+		wall int
+		want bool
+	}{
+		{"centre", "7/7/7/7/7/3x3 o", 0, true},
+		{"next to the centre", "7/7/7/7/7/2x4 o", 0, true},
+		{"edge", "7/7/7/7/7/x6 o", 0, false},
+		{"empty board", "7/7/7/7/7/7 x", 0, false},
+		{"wall in the centre", "", 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var board *game.Board
+			if tt.wall != 0 {
+				board = wallBoard(t, tt.wall-1)
+			} else {
+				var err error
+				if board, _, err = game.DecodeFEN(tt.fen); err != nil {
+					t.Fatalf("DecodeFEN(%q): %v", tt.fen, err)
+				}
+			}
+
+			if got := NewBot(2).ShouldSwap(board); got != tt.want {
+				t.Errorf("ShouldSwap = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// wallBoard returns the board of an arcade game in which player 1 has put a
+// wall into col.
+func wallBoard(t *testing.T, col int) *game.Board {
+	t.Helper()
+	g, err := game.NewGameWithSettings(&models.Player{ID: "p1", Username: "alice", Piece: 1}, false,
+		models.GameSettings{Ruleset: models.RulesetArcade, Mode: models.ModeCasual})
+	if err != nil {
+		t.Fatalf("NewGameWithSettings: %v", err)
+	}
+	g.AddPlayer2(&models.Player{ID: "p2", Username: "bob", Piece: 2})
+	g.Start(nil)
+	t.Cleanup(g.Close)
+
+	if err := g.UsePowerUp(models.MoveWall, col, 1); err != nil {
+		t.Fatalf("UsePowerUp: %v", err)
+	}
+	return g.BoardSnapshot()
+}
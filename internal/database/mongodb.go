@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -69,6 +70,15 @@ func createIndexes(ctx context.Context, db *mongo.Database) error {
 		return err
 	}
 
+	// Chat logs are read back a game at a time
+	chatCollection := db.Collection("chat_logs")
+	_, err = chatCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "game_id", Value: 1}, {Key: "sent_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
 	// Game stats collection indexes
 	statsCollection := db.Collection("game_stats")
 	_, err = statsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
@@ -2,10 +2,15 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -42,10 +47,24 @@ func (db *DB) SaveGame(game *models.Game) error {
 		"winner_id":   nil,
 		"is_bot":      game.IsBot,
 		"status":      game.Status,
+		"end_reason":  game.EndReason,
+		"moves":       game.Moves,
+		"settings":    game.Settings,
+		"start_fen":   game.StartFEN,
+		"ruleset":     game.Settings.Ruleset,
+		"opening":     game.Settings.Opening,
+		"match_id":    game.Settings.MatchID,
+		"seats":       game.Seats,
+		"placements":  game.Placements,
+		"teams":       game.Settings.Teams,
 		"created_at":  game.CreatedAt,
 		"finished_at": game.FinishedAt,
 	}
 
+	if game.Settings.TurnSeconds > 0 {
+		gameDoc["time_control"] = fmt.Sprintf("%d/move", game.Settings.TurnSeconds)
+	}
+
 	if game.Player1 != nil {
 		gameDoc["player1_id"] = game.Player1.ID
 		gameDoc["player1_username"] = game.Player1.Username
@@ -65,12 +84,165 @@ func (db *DB) SaveGame(game *models.Game) error {
 	return err
 }
 
+func (db *DB) GetGameRecord(gameID string) (*models.GameRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("games")
+
+	var record models.GameRecord
+	if err := collection.FindOne(ctx, bson.M{"_id": gameID}).Decode(&record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// GetPlayerGameRecords returns every stored game the player took part in,
+// oldest first.
+func (db *DB) GetPlayerGameRecords(username string) ([]models.GameRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("games")
+
+	filter := bson.M{"$or": []bson.M{
+		{"player1_username": username},
+		{"player2_username": username},
+		{"seats.username": username},
+	}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.GameRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetMatchRecords returns the stored games of a balanced mini-match.
+func (db *DB) GetMatchRecords(matchID string) ([]models.GameRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("games")
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"match_id": matchID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.GameRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetOpeningStats totals the results of finished games by the book opening
+// they started from. Imported games are left out.
+func (db *DB) GetOpeningStats() ([]models.OpeningStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("games")
+
+	count := func(cond bson.M) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{cond, 1, 0}}}
+	}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"opening": bson.M{"$nin": bson.A{nil, ""}},
+			"status":  models.StatusFinished,
+			"source":  bson.M{"$ne": models.SourceImport},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":          "$opening",
+			"games":        bson.M{"$sum": 1},
+			"player1_wins": count(bson.M{"$and": bson.A{bson.M{"$ne": bson.A{"$winner_id", nil}}, bson.M{"$eq": bson.A{"$winner_id", "$player1_id"}}}}),
+			"player2_wins": count(bson.M{"$and": bson.A{bson.M{"$ne": bson.A{"$winner_id", nil}}, bson.M{"$eq": bson.A{"$winner_id", "$player2_id"}}}}),
+			"draws":        count(bson.M{"$eq": bson.A{"$winner_id", nil}}),
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []models.OpeningStats
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// InsertGameRecord stores a game that was played elsewhere, marked as
+// imported. It does not touch the leaderboard.
+func (db *DB) InsertGameRecord(record *models.GameRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	record.Source = models.SourceImport
+
+	collection := db.Database.Collection("games")
+
+	_, err := collection.InsertOne(ctx, record)
+	return err
+}
+
+// ForEachGameRecord streams every stored game to fn, stopping at the first
+// error fn returns.
+func (db *DB) ForEachGameRecord(fn func(*models.GameRecord) error) error {
+	ctx := context.Background()
+
+	collection := db.Database.Collection("games")
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record models.GameRecord
+		if err := cursor.Decode(&record); err != nil {
+			return err
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
 func (db *DB) UpdateGameStats(game *models.Game) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	collection := db.Database.Collection("game_stats")
 
+	if len(game.Placements) > 0 {
+		return db.updatePlacementStats(ctx, game.Placements)
+	}
+	if game.Settings.Teams {
+		return db.updateTeamStats(ctx, game)
+	}
+
 	if game.Winner != nil {
 		// Update winner stats
 		_, err := collection.UpdateOne(
@@ -92,10 +264,14 @@ func (db *DB) UpdateGameStats(game *models.Game) error {
 			return err
 		}
 
-		// Update loser stats
-		loser := game.Player1
-		if game.Winner.ID == game.Player1.ID {
-			loser = game.Player2
+		// Update loser stats. Seats can be exchanged by the swap rule, so
+		// the loser is whichever player is not the winner rather than a
+		// fixed seat
+		var loser *models.Player
+		for _, player := range []*models.Player{game.Player1, game.Player2} {
+			if player != nil && player.ID != game.Winner.ID {
+				loser = player
+			}
 		}
 
 		if loser != nil {
@@ -158,6 +334,122 @@ func (db *DB) UpdateGameStats(game *models.Game) error {
 	return nil
 }
 
+// updatePlacementStats scores a free-for-all game: sole first place is a win,
+// a shared first place a draw, and any other place a loss.
+func (db *DB) updatePlacementStats(ctx context.Context, placements []models.Placement) error {
+	collection := db.Database.Collection("game_stats")
+
+	first := 0
+	for _, placement := range placements {
+		if placement.Place == 1 {
+			first++
+		}
+	}
+
+	for _, placement := range placements {
+		field := "losses"
+		switch {
+		case placement.Place == 1 && first == 1:
+			field = "wins"
+		case placement.Place == 1:
+			field = "draws"
+		}
+
+		setOnInsert := bson.M{"wins": 0, "losses": 0, "draws": 0}
+		delete(setOnInsert, field)
+
+		_, err := collection.UpdateOne(
+			ctx,
+			bson.M{"username": placement.Username},
+			bson.M{
+				"$inc": bson.M{
+					field:         1,
+					"total_games": 1,
+				},
+				"$setOnInsert": setOnInsert,
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateTeamStats scores a team game for both pairs of teammates. Team
+// games do not count towards the players' own records.
+func (db *DB) updateTeamStats(ctx context.Context, game *models.Game) error {
+	collection := db.Database.Collection("team_stats")
+
+	for team := 1; team <= 2; team++ {
+		field := "draws"
+		switch game.WinningTeam {
+		case 0:
+		case team:
+			field = "wins"
+		default:
+			field = "losses"
+		}
+
+		// Seats alternate between the teams
+		var members []string
+		for i, player := range game.Seats {
+			if i%2 == team-1 {
+				members = append(members, player.Username)
+			}
+		}
+		sort.Strings(members)
+
+		setOnInsert := bson.M{"members": members, "wins": 0, "losses": 0, "draws": 0}
+		delete(setOnInsert, field)
+
+		_, err := collection.UpdateOne(
+			ctx,
+			bson.M{"team": strings.Join(members, " & ")},
+			bson.M{
+				"$inc": bson.M{
+					field:         1,
+					"total_games": 1,
+				},
+				"$setOnInsert": setOnInsert,
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetTeamLeaderboard ranks pairs of teammates by team game wins.
+func (db *DB) GetTeamLeaderboard(limit int) ([]models.TeamLeaderboardEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("team_stats")
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "wins", Value: -1}, {Key: "total_games", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var leaderboard []models.TeamLeaderboardEntry
+	if err = cursor.All(ctx, &leaderboard); err != nil {
+		return nil, err
+	}
+
+	return leaderboard, nil
+}
+
 func (db *DB) GetLeaderboard(limit int) ([]models.LeaderboardEntry, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -180,4 +472,212 @@ func (db *DB) GetLeaderboard(limit int) ([]models.LeaderboardEntry, error) {
 	}
 
 	return leaderboard, nil
-}
\ No newline at end of file
+}
+
+// GetPlayerStats returns the records of the given players. Players with no
+// rated games yet have no record and are left out.
+func (db *DB) GetPlayerStats(usernames []string) ([]models.LeaderboardEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("game_stats")
+
+	cursor, err := collection.Find(ctx, bson.M{"username": bson.M{"$in": usernames}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []models.LeaderboardEntry
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetHeadToHead totals the finished games between two players, in either
+// seat. Imported games are left out.
+func (db *DB) GetHeadToHead(username1, username2 string) (*models.HeadToHead, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("games")
+
+	filter := bson.M{
+		"status": models.StatusFinished,
+		"source": bson.M{"$ne": models.SourceImport},
+		"$or": []bson.M{
+			{"player1_username": username1, "player2_username": username2},
+			{"player1_username": username2, "player2_username": username1},
+		},
+	}
+	opts := options.Find().SetProjection(bson.M{"winner_username": 1})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	score := &models.HeadToHead{Wins: map[string]int{username1: 0, username2: 0}}
+	for cursor.Next(ctx) {
+		var game struct {
+			WinnerUsername string `bson:"winner_username"`
+		}
+		if err := cursor.Decode(&game); err != nil {
+			return nil, err
+		}
+
+		score.Games++
+		if game.WinnerUsername == "" {
+			score.Draws++
+		} else {
+			score.Wins[game.WinnerUsername]++
+		}
+	}
+
+	return score, cursor.Err()
+}
+
+// SaveChatMessage logs a chat message with its game.
+func (db *DB) SaveChatMessage(message *models.ChatMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("chat_logs")
+	_, err := collection.InsertOne(ctx, message)
+	return err
+}
+
+// GetChatLog returns the chat of a game in the order it was sent.
+func (db *DB) GetChatLog(gameID string) ([]models.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("chat_logs")
+
+	opts := options.Find().SetSort(bson.D{{Key: "sent_at", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"game_id": gameID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.ChatMessage{}
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetBlockedPlayers returns the usernames a player has blocked.
+func (db *DB) GetBlockedPlayers(username string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("players")
+
+	var player struct {
+		Blocked []string `bson:"blocked"`
+	}
+	err := collection.FindOne(ctx, bson.M{"username": username}).Decode(&player)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return player.Blocked, err
+}
+
+// SetBlocked adds a player to another's block list or takes them off it.
+func (db *DB) SetBlocked(username, blocked string, block bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("players")
+
+	op := "$pull"
+	if block {
+		op = "$addToSet"
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{op: bson.M{"blocked": blocked}})
+	return err
+}
+
+// IncrementEmoteCount counts one more use of an emote.
+func (db *DB) IncrementEmoteCount(emote string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("emote_stats")
+
+	opts := options.Update().SetUpsert(true)
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": emote}, bson.M{"$inc": bson.M{"count": 1}}, opts)
+	return err
+}
+
+// GetEmoteStats returns how often each emote has been sent. Emotes never
+// sent are left out.
+func (db *DB) GetEmoteStats() ([]models.EmoteStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("emote_stats")
+
+	opts := options.Find().SetSort(bson.D{{Key: "count", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []models.EmoteStats
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// SaveActiveGame writes the full state of an in-progress game so it can be
+// recovered after a restart.
+func (db *DB) SaveActiveGame(game *models.Game) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("active_games")
+
+	opts := options.Replace().SetUpsert(true)
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": game.ID}, game, opts)
+	return err
+}
+
+func (db *DB) DeleteActiveGame(gameID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("active_games")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": gameID})
+	return err
+}
+
+func (db *DB) LoadActiveGames() ([]*models.Game, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := db.Database.Collection("active_games")
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var games []*models.Game
+	if err = cursor.All(ctx, &games); err != nil {
+		return nil, err
+	}
+
+	return games, nil
+}
@@ -7,12 +7,20 @@ const (
 
 type Board struct {
     grid [][]int
+    // cube marks a 3D board, which wins along the cube's lines instead
+    cube bool
 }
 
 func NewBoard() *Board {
-    grid := make([][]int, Rows)
+    return NewBoardSize(Rows, Cols)
+}
+
+// NewBoardSize creates an empty board of a non-standard size, as used by the
+// multi-player variant.
+func NewBoardSize(rows, cols int) *Board {
+    grid := make([][]int, rows)
     for i := range grid {
-        grid[i] = make([]int, Cols)
+        grid[i] = make([]int, cols)
     }
     return &Board{grid: grid}
 }
@@ -21,8 +29,16 @@ func (b *Board) GetGrid() [][]int {
     return b.grid
 }
 
+func (b *Board) Rows() int {
+    return len(b.grid)
+}
+
+func (b *Board) Cols() int {
+    return len(b.grid[0])
+}
+
 func (b *Board) IsValidMove(col int) bool {
-    if col < 0 || col >= Cols {
+    if col < 0 || col >= b.Cols() {
         return false
     }
     return b.grid[0][col] == 0
@@ -33,7 +49,7 @@ func (b *Board) MakeMove(col int, player int) (int, bool) {
         return -1, false
     }
 
-    for row := Rows - 1; row >= 0; row-- {
+    for row := b.Rows() - 1; row >= 0; row-- {
         if b.grid[row][col] == 0 {
             b.grid[row][col] = player
             return row, true
@@ -42,7 +58,27 @@ func (b *Board) MakeMove(col int, player int) (int, bool) {
     return -1, false
 }
 
+// UnmakeMove removes the top piece of a column and returns the row it was
+// in and the player it belonged to.
+func (b *Board) UnmakeMove(col int) (int, int, bool) {
+    if col < 0 || col >= b.Cols() {
+        return -1, 0, false
+    }
+
+    for row := 0; row < b.Rows(); row++ {
+        if b.grid[row][col] != 0 {
+            player := b.grid[row][col]
+            b.grid[row][col] = 0
+            return row, player, true
+        }
+    }
+    return -1, 0, false
+}
+
 func (b *Board) CheckWin(row, col, player int) bool {
+    if b.cube {
+        return b.checkCubeLines(row, col, player)
+    }
     // Check horizontal
     if b.checkDirection(row, col, 0, 1, player) {
         return true
@@ -64,10 +100,11 @@ func (b *Board) CheckWin(row, col, player int) bool {
 
 func (b *Board) checkDirection(row, col, dRow, dCol, player int) bool {
     count := 1
+    rows, cols := b.Rows(), b.Cols()
 
     // Check positive direction
     r, c := row+dRow, col+dCol
-    for r >= 0 && r < Rows && c >= 0 && c < Cols && b.grid[r][c] == player {
+    for r >= 0 && r < rows && c >= 0 && c < cols && b.grid[r][c] == player {
         count++
         r += dRow
         c += dCol
@@ -75,7 +112,7 @@ func (b *Board) checkDirection(row, col, dRow, dCol, player int) bool {
 
     // Check negative direction
     r, c = row-dRow, col-dCol
-    for r >= 0 && r < Rows && c >= 0 && c < Cols && b.grid[r][c] == player {
+    for r >= 0 && r < rows && c >= 0 && c < cols && b.grid[r][c] == player {
         count++
         r -= dRow
         c -= dCol
@@ -84,8 +121,20 @@ func (b *Board) checkDirection(row, col, dRow, dCol, player int) bool {
     return count >= 4
 }
 
+// HasFour reports whether player has four in a row anywhere on the board.
+func (b *Board) HasFour(player int) bool {
+    for row := 0; row < b.Rows(); row++ {
+        for col := 0; col < b.Cols(); col++ {
+            if b.grid[row][col] == player && b.CheckWin(row, col, player) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
 func (b *Board) IsFull() bool {
-    for col := 0; col < Cols; col++ {
+    for col := 0; col < b.Cols(); col++ {
         if b.grid[0][col] == 0 {
             return false
         }
@@ -95,7 +144,7 @@ func (b *Board) IsFull() bool {
 
 func (b *Board) GetAvailableColumns() []int {
     available := []int{}
-    for col := 0; col < Cols; col++ {
+    for col := 0; col < b.Cols(); col++ {
         if b.IsValidMove(col) {
             available = append(available, col)
         }
@@ -104,10 +153,10 @@ func (b *Board) GetAvailableColumns() []int {
 }
 
 func (b *Board) Clone() *Board {
-    newGrid := make([][]int, Rows)
+    newGrid := make([][]int, b.Rows())
     for i := range b.grid {
-        newGrid[i] = make([]int, Cols)
+        newGrid[i] = make([]int, b.Cols())
         copy(newGrid[i], b.grid[i])
     }
-    return &Board{grid: newGrid}
+    return &Board{grid: newGrid, cube: b.cube}
 }
\ No newline at end of file
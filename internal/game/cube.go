@@ -0,0 +1,108 @@
+package game
+
+// The 3D variant is Score Four: a 4x4 grid of pegs, each holding up to four
+// pieces, won by four in a line in any direction through the cube. It uses
+// the ordinary Board with one column per peg and one row per height, top
+// first, so dropping, undoing, persisting and sending the board work as in
+// the classic game; only the winning lines differ.
+//
+// Peg (x, y) of the grid is column y*CubeSize+x, so on the wire the board is
+// CubeSize rows of CubeSize*CubeSize cells and a move's column is the peg.
+
+// CubeSize is the length of each edge of the 3D board.
+const CubeSize = 4
+
+// Cell is a position on a board.
+type Cell struct {
+	Row int
+	Col int
+}
+
+// cubeLines lists the 76 lines of four on the 3D board, and cellLines the
+// lines through each cell by row and column.
+var cubeLines, cellLines = buildCubeLines()
+
+// NewCube creates an empty 3D board.
+func NewCube() *Board {
+	b := NewBoardSize(CubeSize, CubeSize*CubeSize)
+	b.cube = true
+	return b
+}
+
+// IsCube reports whether the board is a 3D board.
+func (b *Board) IsCube() bool {
+	return b.cube
+}
+
+// Peg returns the grid position of a 3D board column.
+func Peg(col int) (x, y int) {
+	return col % CubeSize, col / CubeSize
+}
+
+// CubeLinesThrough returns the lines of four on the 3D board that pass
+// through a cell.
+func CubeLinesThrough(row, col int) [][CubeSize]Cell {
+	lines := make([][CubeSize]Cell, 0, len(cellLines[row][col]))
+	for _, i := range cellLines[row][col] {
+		lines = append(lines, cubeLines[i])
+	}
+	return lines
+}
+
+func (b *Board) checkCubeLines(row, col, player int) bool {
+	for _, i := range cellLines[row][col] {
+		won := true
+		for _, cell := range cubeLines[i] {
+			if b.grid[cell.Row][cell.Col] != player {
+				won = false
+				break
+			}
+		}
+		if won {
+			return true
+		}
+	}
+	return false
+}
+
+// buildCubeLines walks the 13 directions that cover every line once. On an
+// edge of exactly four, a line starts at the one cell from which four steps
+// stay inside the cube.
+func buildCubeLines() ([][CubeSize]Cell, [CubeSize][CubeSize * CubeSize][]int) {
+	var lines [][CubeSize]Cell
+	var byCell [CubeSize][CubeSize * CubeSize][]int
+
+	inside := func(v int) bool { return v >= 0 && v < CubeSize }
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dz := -1; dz <= 1; dz++ {
+				// Keep one of each pair of opposite directions
+				if dx < 0 || (dx == 0 && dy < 0) || (dx == 0 && dy == 0 && dz <= 0) {
+					continue
+				}
+				for x := 0; x < CubeSize; x++ {
+					for y := 0; y < CubeSize; y++ {
+						for z := 0; z < CubeSize; z++ {
+							end := CubeSize - 1
+							if !inside(x+dx*end) || !inside(y+dy*end) || !inside(z+dz*end) {
+								continue
+							}
+							var line [CubeSize]Cell
+							for step := 0; step < CubeSize; step++ {
+								line[step] = Cell{
+									Row: z + dz*step,
+									Col: (y+dy*step)*CubeSize + x + dx*step,
+								}
+							}
+							for _, cell := range line {
+								byCell[cell.Row][cell.Col] = append(byCell[cell.Row][cell.Col], len(lines))
+							}
+							lines = append(lines, line)
+						}
+					}
+				}
+			}
+		}
+	}
+	return lines, byCell
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func TestCubeLines(t *testing.T) {
+	if len(cubeLines) != 76 {
+		t.Fatalf("%d lines, want 76", len(cubeLines))
+	}
+
+	seen := map[[CubeSize]Cell]bool{}
+	for _, line := range cubeLines {
+		if seen[line] {
+			t.Errorf("line %v is listed twice", line)
+		}
+		seen[line] = true
+
+		// Each step moves the same way in x, y and height
+		x0, y0 := Peg(line[0].Col)
+		x1, y1 := Peg(line[1].Col)
+		dx, dy, dz := x1-x0, y1-y0, line[1].Row-line[0].Row
+		for i := 1; i < CubeSize; i++ {
+			xa, ya := Peg(line[i-1].Col)
+			xb, yb := Peg(line[i].Col)
+			if xb-xa != dx || yb-ya != dy || line[i].Row-line[i-1].Row != dz {
+				t.Errorf("line %v is not straight", line)
+				break
+			}
+		}
+	}
+
+	// Corners and the eight centre cells lie on 7 lines, every other
+	// cell on 4
+	tests := []struct {
+		name      string
+		x, y, z   int
+		wantLines int
+	}{
+		{"corner", 0, 0, 0, 7},
+		{"top corner", 3, 3, 3, 7},
+		{"centre", 1, 2, 1, 7},
+		{"edge", 1, 0, 0, 4},
+		{"face", 1, 1, 0, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := CubeLinesThrough(tt.z, tt.y*CubeSize+tt.x)
+			if len(lines) != tt.wantLines {
+				t.Errorf("%d lines through (%d, %d, %d), want %d", len(lines), tt.x, tt.y, tt.z, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestCubeWin(t *testing.T) {
+	tests := []struct {
+		name string
+		// drops are pegs played in order, each by the player given in
+		// players at the same index. The last drop is checked.
+		drops   []int
+		players []int
+		want    bool
+	}{
+		{"up a peg", []int{5, 5, 5, 5}, []int{1, 1, 1, 1}, true},
+		{"along a row", []int{0, 1, 2, 3}, []int{2, 2, 2, 2}, true},
+		{"across the bottom diagonally", []int{0, 5, 10, 15}, []int{1, 1, 1, 1}, true},
+		{
+			name:    "through the cube",
+			drops:   []int{5, 10, 10, 15, 15, 15, 0, 5, 10, 15},
+			players: []int{2, 2, 2, 2, 2, 2, 1, 1, 1, 1},
+			want:    true,
+		},
+		{"three in a row", []int{0, 1, 2}, []int{1, 1, 1}, false},
+		{"broken row", []int{0, 1, 2, 3}, []int{1, 1, 2, 1}, false},
+		{"wrapping across pegs", []int{2, 3, 4, 5}, []int{1, 1, 1, 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := NewCube()
+			row, peg, player := 0, 0, 0
+			for i, drop := range tt.drops {
+				var ok bool
+				if row, ok = board.MakeMove(drop, tt.players[i]); !ok {
+					t.Fatalf("drop %d on peg %d refused", i+1, drop)
+				}
+				peg, player = drop, tt.players[i]
+			}
+			if got := board.CheckWin(row, peg, player); got != tt.want {
+				t.Errorf("CheckWin = %v, want %v", got, tt.want)
+			}
+			if got := board.HasFour(player); got != tt.want {
+				t.Errorf("HasFour = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCubeGame(t *testing.T) {
+	g := newTestTwoPlayerGame(t, models.GameSettings{Ruleset: models.Ruleset3D}, "")
+
+	state := g.Snapshot()
+	if len(state.Board) != CubeSize || len(state.Board[0]) != CubeSize*CubeSize {
+		t.Fatalf("board is %d by %d, want %d by %d", len(state.Board), len(state.Board[0]), CubeSize, CubeSize*CubeSize)
+	}
+	if err := g.MakeMove(15, 1); err != nil {
+		t.Errorf("move on the last peg: %v", err)
+	}
+	if err := g.MakeMove(16, 2); !errors.Is(err, ErrInvalidColumn) {
+		t.Errorf("move past the last peg = %v, want ErrInvalidColumn", err)
+	}
+	for i := 0; i < CubeSize-1; i++ {
+		if err := g.MakeMove(15, 2-i%2); err != nil {
+			t.Fatalf("filling peg 15: %v", err)
+		}
+	}
+	if err := g.MakeMove(15, 1); !errors.Is(err, ErrColumnFull) {
+		t.Errorf("move on a full peg = %v, want ErrColumnFull", err)
+	}
+}
@@ -0,0 +1,84 @@
+package game
+
+import "errors"
+
+// Error is a gameplay error with a stable, machine-readable code. Clients
+// match on the code, and on the details some errors carry, rather than on
+// the message, which is only meant for people.
+//
+// Errors compare by code, so errors.Is(err, ErrColumnFull) holds for an
+// ErrColumnFull that has details attached.
+type Error struct {
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// With returns a copy of the error carrying details about this occurrence.
+func (e *Error) With(details map[string]interface{}) *Error {
+	return &Error{Code: e.Code, Message: e.Message, Details: details}
+}
+
+// catalog lists every gameplay error in the order they are declared.
+var catalog []*Error
+
+func newError(code, message string) *Error {
+	err := &Error{Code: code, Message: message}
+	catalog = append(catalog, err)
+	return err
+}
+
+// Catalog returns every gameplay error code with its default message.
+func Catalog() []*Error {
+	return catalog
+}
+
+var (
+	ErrGameNotFound   = newError("E_GAME_NOT_FOUND", "game not found")
+	ErrGameNotPlaying = newError("E_GAME_NOT_PLAYING", "game is not in playing state")
+	ErrNotYourTurn    = newError("E_NOT_YOUR_TURN", "not your turn")
+	ErrInvalidColumn  = newError("E_INVALID_COLUMN", "no such column")
+	ErrColumnFull     = newError("E_COLUMN_FULL", "column is full")
+	ErrNotInGame      = newError("E_NOT_IN_GAME", "not a player in this game")
+	ErrGameClosed     = newError("E_GAME_CLOSED", "game is closed")
+	ErrTooManyGames   = newError("E_TOO_MANY_GAMES", "too many games in progress, try again later")
+
+	ErrInvalidSettings = newError("E_INVALID_SETTINGS", "invalid game settings")
+
+	ErrTakebackNotAllowed = newError("E_TAKEBACK_NOT_ALLOWED", "takebacks are only allowed in casual and practice games")
+	ErrNothingToTakeBack  = newError("E_NOTHING_TO_TAKE_BACK", "no move to take back")
+	ErrTakebackPending    = newError("E_TAKEBACK_PENDING", "a takeback request is already pending")
+	ErrNoTakebackPending  = newError("E_NO_TAKEBACK_PENDING", "no takeback request to answer")
+
+	ErrSwapNotAllowed = newError("E_SWAP_NOT_ALLOWED", "swap is only allowed as the reply to the first move of a swap-rule game")
+
+	ErrEliminated   = newError("E_ELIMINATED", "player is already out of this game")
+	ErrTooManySeats = newError("E_TOO_MANY_SEATS", "games for more than two players are only started by group matchmaking")
+
+	ErrPowerUpsDisabled = newError("E_POWERUPS_DISABLED", "power-ups are only available in arcade games")
+	ErrUnknownPowerUp   = newError("E_UNKNOWN_POWERUP", "unknown power-up")
+	ErrNoPowerUpLeft    = newError("E_NO_POWERUP_LEFT", "no power-ups of that kind left")
+)
+
+// invalidSettings reports why a game's settings were rejected. The reason
+// is both the message and a detail.
+func invalidSettings(reason error) error {
+	var gameErr *Error
+	if errors.As(reason, &gameErr) {
+		return reason
+	}
+	return &Error{
+		Code:    ErrInvalidSettings.Code,
+		Message: reason.Error(),
+		Details: map[string]interface{}{"reason": reason.Error()},
+	}
+}
@@ -0,0 +1,178 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Free-for-all games seat three or four players on a wider board. Turns
+// rotate through the seats in order. Connecting four wins outright; a player
+// who resigns or runs out of time is knocked out and placed last among those
+// still in, and the game goes on until one player is left.
+
+// MaxSeats is the largest number of players in a game.
+const MaxSeats = 4
+
+// BoardSize returns the rows and columns of the board for a game with the
+// given number of players.
+func BoardSize(seats int) (int, int) {
+	switch seats {
+	case 3:
+		return 7, 9
+	case 4:
+		return 8, 10
+	}
+	return Rows, Cols
+}
+
+// SeatCount returns the number of players in a game.
+func SeatCount(game *models.Game) int {
+	if n := len(game.Seats); n > 0 {
+		return n
+	}
+	return 2
+}
+
+// Players returns the seated players of a game in seat order.
+func Players(game *models.Game) []*models.Player {
+	if len(game.Seats) > 0 {
+		return game.Seats
+	}
+
+	var players []*models.Player
+	for _, player := range []*models.Player{game.Player1, game.Player2} {
+		if player != nil {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+// NewGroupGame creates a free-for-all game for three or four players, seated
+// in the order given. For two players it is an ordinary game.
+func NewGroupGame(players []*models.Player, settings models.GameSettings) (*GameInstance, error) {
+	if len(players) < 2 || len(players) > MaxSeats {
+		return nil, invalidSettings(fmt.Errorf("a game needs 2 to %d players, got %d", MaxSeats, len(players)))
+	}
+	if len(players) == 2 {
+		settings.Seats = 0
+		players[0].Piece = 1
+		g, err := NewGameWithSettings(players[0], false, settings)
+		if err != nil {
+			return nil, err
+		}
+		players[1].Piece = 2
+		g.AddPlayer2(players[1])
+		return g, nil
+	}
+
+	settings.Seats = len(players)
+	if _, _, _, err := StartingPosition(settings); err != nil {
+		return nil, err
+	}
+
+	for i, player := range players {
+		player.Piece = i + 1
+	}
+	rows, cols := BoardSize(len(players))
+	board := NewBoardSize(rows, cols)
+
+	game := &models.Game{
+		ID:          uuid.New().String(),
+		Player1:     players[0],
+		Player2:     players[1],
+		Seats:       players,
+		Board:       board.GetGrid(),
+		CurrentTurn: 1,
+		Status:      models.StatusPlaying,
+		Moves:       []models.Move{},
+		Settings:    settings,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	return newInstance(game, board), nil
+}
+
+// eliminate knocks a player out of a free-for-all game. The last player
+// standing wins.
+func (g *GameInstance) eliminate(seat int, reason string) {
+	g.place(seat, g.activeSeats())
+
+	entry := models.Move{
+		GameID: g.ID,
+		Player: seat,
+		Type:   models.MoveEliminated,
+	}
+	g.state.Moves = append(g.state.Moves, entry)
+	g.state.UpdatedAt = time.Now()
+
+	if g.activeSeats() == 1 {
+		last := g.nextSeat(seat)
+		g.place(last, 1)
+		g.finish(g.playerBySeat(last), reason)
+		g.persist()
+		g.publish(UpdateEnd, nil, reason)
+		return
+	}
+
+	if seat == g.state.CurrentTurn {
+		g.state.CurrentTurn = g.nextSeat(seat)
+	}
+	// The entry above moves the ply the turn timer is waiting on, so it is
+	// armed again even when the player to move stays the same
+	g.armTimer()
+	g.persist()
+	g.publishUpdate(Update{Type: UpdateEliminated, Move: &entry, Result: reason, Seat: seat})
+}
+
+// placeRemaining gives every player still in the game the same place when
+// the game ends on the board.
+func (g *GameInstance) placeRemaining(place int) {
+	for seat := 1; seat <= SeatCount(g.state); seat++ {
+		if !g.isOut(seat) {
+			g.place(seat, place)
+		}
+	}
+}
+
+func (g *GameInstance) place(seat, place int) {
+	player := g.playerBySeat(seat)
+	g.state.Placements = append(g.state.Placements, models.Placement{
+		Seat:     seat,
+		PlayerID: player.ID,
+		Username: player.Username,
+		Place:    place,
+	})
+}
+
+func (g *GameInstance) isOut(seat int) bool {
+	for _, placement := range g.state.Placements {
+		if placement.Seat == seat {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *GameInstance) activeSeats() int {
+	return SeatCount(g.state) - len(g.state.Placements)
+}
+
+// nextSeat returns the seat after the given one that is still in the game.
+func (g *GameInstance) nextSeat(seat int) int {
+	return nextActiveSeat(seat, SeatCount(g.state), g.isOut)
+}
+
+func nextActiveSeat(seat, seats int, out func(int) bool) int {
+	for i := 1; i <= seats; i++ {
+		next := (seat+i-1)%seats + 1
+		if !out(next) {
+			return next
+		}
+	}
+	return seat
+}
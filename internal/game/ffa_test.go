@@ -0,0 +1,113 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func newTestGroupGame(t *testing.T, seats int, timeout time.Duration) *GameInstance {
+	t.Helper()
+	var players []*models.Player
+	for i := 1; i <= seats; i++ {
+		players = append(players, &models.Player{ID: fmt.Sprintf("p%d", i), Username: fmt.Sprintf("player%d", i)})
+	}
+	g, err := NewGroupGame(players, models.GameSettings{})
+	if err != nil {
+		t.Fatalf("NewGroupGame: %v", err)
+	}
+	g.TurnTimeout = timeout
+	g.Start(nil)
+	t.Cleanup(g.Close)
+	return g
+}
+
+func TestEliminateOnResign(t *testing.T) {
+	tests := []struct {
+		name     string
+		resign   int
+		wantTurn int
+	}{
+		{"player to move", 1, 2},
+		{"player not to move", 2, 1},
+		{"last seat, not to move", 4, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGroupGame(t, 4, time.Minute)
+			if err := g.Resign(tt.resign); err != nil {
+				t.Fatalf("Resign(%d): %v", tt.resign, err)
+			}
+
+			state := g.Snapshot()
+			if state.Status != models.StatusPlaying {
+				t.Fatalf("status = %s, want playing", state.Status)
+			}
+			if state.CurrentTurn != tt.wantTurn {
+				t.Errorf("current turn = %d, want %d", state.CurrentTurn, tt.wantTurn)
+			}
+			if len(state.Placements) != 1 || state.Placements[0].Seat != tt.resign || state.Placements[0].Place != 4 {
+				t.Errorf("placements = %+v, want seat %d in 4th place", state.Placements, tt.resign)
+			}
+			if err := g.Resign(tt.resign); err != ErrEliminated {
+				t.Errorf("second Resign(%d) = %v, want ErrEliminated", tt.resign, err)
+			}
+		})
+	}
+}
+
+func TestEliminateLastStandingWins(t *testing.T) {
+	g := newTestGroupGame(t, 3, time.Minute)
+	for _, seat := range []int{3, 1} {
+		if err := g.Resign(seat); err != nil {
+			t.Fatalf("Resign(%d): %v", seat, err)
+		}
+	}
+
+	state := g.Snapshot()
+	if state.Status != models.StatusFinished {
+		t.Fatalf("status = %s, want finished", state.Status)
+	}
+	if state.Winner == nil || state.Winner.ID != "p2" {
+		t.Errorf("winner = %+v, want p2", state.Winner)
+	}
+	places := map[int]int{}
+	for _, placement := range state.Placements {
+		places[placement.Seat] = placement.Place
+	}
+	want := map[int]int{1: 2, 2: 1, 3: 3}
+	for seat, place := range want {
+		if places[seat] != place {
+			t.Errorf("seat %d placed %d, want %d", seat, places[seat], place)
+		}
+	}
+}
+
+// A resignation by a player not on turn adds a move entry, which the turn
+// timer of the player to move is keyed on; it must still fire.
+func TestTimeoutAfterResignOutOfTurn(t *testing.T) {
+	g := newTestGroupGame(t, 3, 50*time.Millisecond)
+	if err := g.Resign(2); err != nil {
+		t.Fatalf("Resign(2): %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		state := g.Snapshot()
+		if state.Status == models.StatusFinished {
+			if state.EndReason != ResultTimeout {
+				t.Errorf("end reason = %q, want %q", state.EndReason, ResultTimeout)
+			}
+			if state.Winner == nil || state.Winner.ID != "p3" {
+				t.Errorf("winner = %+v, want p3", state.Winner)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("game still %s with placements %+v; the turn timer never fired", state.Status, state.Placements)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
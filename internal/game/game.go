@@ -1,89 +1,716 @@
 package game
 
 import (
+    "errors"
+    "log"
+    "sync"
     "time"
     "github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
     "github.com/google/uuid"
 )
 
+// DefaultTurnTimeout is how long a player may think before losing on time.
+const DefaultTurnTimeout = 2 * time.Minute
+
+// The range a game's settings may set the time per move to, in seconds.
+const (
+    MinTurnSeconds = 10
+    MaxTurnSeconds = 600
+)
+
+// Result values reported in an Update.
+const (
+    ResultContinue = "continue"
+    ResultWin      = "win"
+    ResultDraw     = "draw"
+    ResultResign   = "resign"
+    ResultTimeout  = "timeout"
+    ResultAbandoned = "abandoned"
+)
+
+// Update types published by a running game.
+const (
+    UpdateMove     = "move_made"
+    UpdateEnd      = "game_end"
+    UpdateRestored = "game_restored"
+
+    UpdateTakebackRequested = "takeback_requested"
+    UpdateTakeback          = "takeback"
+    UpdateTakebackDeclined  = "takeback_declined"
+    UpdateSwap              = "swap"
+    UpdateEliminated        = "player_eliminated"
+)
+
+// Update describes a state change of a game. Game is a snapshot owned by the
+// receiver and can be marshaled or kept without further locking.
+type Update struct {
+    Type   string
+    Move   *models.Move
+    Result string
+    Game   *models.Game
+    // Seat is the player an update concerns, such as a takeback requester.
+    Seat int
+    // Undone lists the takeback entries recorded by a takeback.
+    Undone []models.Move
+}
+
+// RejoinState is what a reconnecting player needs to resume play.
+type RejoinState struct {
+    Seat   int
+    Game   *models.Game
+    Missed []models.Move
+}
+
+type commandKind int
+
+const (
+    cmdMove commandKind = iota
+    cmdResign
+    cmdTimeout
+    cmdAbandon
+    cmdRejoin
+    cmdSnapshot
+    cmdBoard
+    cmdSeat
+    cmdRequestTakeback
+    cmdRespondTakeback
+    cmdSwap
+    cmdPowerUp
+)
+
+type command struct {
+    kind      commandKind
+    column    int
+    playerNum int
+    playerID  string
+    since     int
+    ply       int
+    accept    bool
+    powerUp   string
+    reply     chan reply
+}
+
+type reply struct {
+    err    error
+    seat   int
+    game   *models.Game
+    board  *Board
+    rejoin *RejoinState
+}
+
+// GameInstance is a live game. Once started, all state is owned by a single
+// goroutine that applies commands one at a time; other goroutines only ever
+// see snapshots.
 type GameInstance struct {
-    *models.Game
-    board *Board
+    ID          string
+    TurnTimeout time.Duration
+
+    state    *models.Game
+    board    *Board
+    store    Store
+    restored bool
+    commands chan command
+    updates  *updateQueue
+    done     chan struct{}
+    timer    *time.Timer
+    start    sync.Once
+    stop     sync.Once
 }
 
 func NewGame(player1 *models.Player, isBot bool) *GameInstance {
-    board := NewBoard()
+    g, _ := NewGameWithSettings(player1, isBot, models.GameSettings{})
+    return g
+}
+
+// NewGameWithSettings creates a game that may start from a custom position
+// or with a handicap. The starting position is stored with the game so
+// replays start from the same board.
+func NewGameWithSettings(player1 *models.Player, isBot bool, settings models.GameSettings) (*GameInstance, error) {
+    // A balanced game without an opening is the first of a new mini-match
+    if settings.Ruleset == models.RulesetBalanced && settings.Opening == "" {
+        opening, ok := RandomOpening()
+        if !ok {
+            return nil, invalidSettings(errors.New("there are no balanced openings to start from"))
+        }
+        settings.Opening = opening.ID
+        settings.MatchID = uuid.New().String()
+        settings.MatchGame = 1
+    }
+
+    if settings.Seats > 2 {
+        return nil, ErrTooManySeats
+    }
+
+    board, toMove, startFEN, err := StartingPosition(settings)
+    if err != nil {
+        return nil, err
+    }
+
     game := &models.Game{
         ID:          uuid.New().String(),
         Player1:     player1,
         Board:       board.GetGrid(),
-        CurrentTurn: 1,
+        CurrentTurn: toMove,
         Status:      models.StatusWaiting,
         IsBot:       isBot,
+        Moves:       []models.Move{},
+        Settings:    settings,
+        StartFEN:    startFEN,
         CreatedAt:   time.Now(),
         UpdatedAt:   time.Now(),
     }
+    if settings.Ruleset == models.RulesetArcade {
+        game.Inventory = newInventories()
+    }
 
+    return newInstance(game, board), nil
+}
+
+func newInstance(game *models.Game, board *Board) *GameInstance {
+    turnTimeout := DefaultTurnTimeout
+    if game.Settings.TurnSeconds > 0 {
+        turnTimeout = time.Duration(game.Settings.TurnSeconds) * time.Second
+    }
     return &GameInstance{
-        Game:  game,
-        board: board,
+        ID:          game.ID,
+        TurnTimeout: turnTimeout,
+        state:       game,
+        board:       board,
+        commands:    make(chan command),
+        updates:     newUpdateQueue(),
+        done:        make(chan struct{}),
     }
 }
 
+// AddPlayer2 seats the second player. It must be called before Start.
 func (g *GameInstance) AddPlayer2(player2 *models.Player) {
-    g.Player2 = player2
-    g.Status = models.StatusPlaying
-    g.UpdatedAt = time.Now()
+    g.state.Player2 = player2
+    g.state.Status = models.StatusPlaying
+    g.state.UpdatedAt = time.Now()
+}
+
+// Start launches the goroutine that owns the game. Updates are delivered to
+// listener in order on a separate goroutine, so the listener may call back
+// into the game. Calling Start more than once has no effect.
+func (g *GameInstance) Start(listener func(*GameInstance, Update)) {
+    g.start.Do(func() {
+        go g.dispatch(listener)
+        go g.run()
+    })
+}
+
+// Close stops the game's goroutine. Later commands fail with ErrGameClosed.
+func (g *GameInstance) Close() {
+    g.stop.Do(func() {
+        close(g.done)
+    })
+}
+
+func (g *GameInstance) MakeMove(col int, playerNum int) error {
+    return g.do(command{kind: cmdMove, column: col, playerNum: playerNum}).err
+}
+
+func (g *GameInstance) Resign(playerNum int) error {
+    return g.do(command{kind: cmdResign, playerNum: playerNum}).err
+}
+
+// Abandon ends a game that is still waiting for its second player.
+func (g *GameInstance) Abandon() error {
+    return g.do(command{kind: cmdAbandon}).err
 }
 
-func (g *GameInstance) MakeMove(col int, playerNum int) (int, bool, string) {
-    if g.Status != models.StatusPlaying {
-        return -1, false, "game is not in playing state"
+// Rejoin returns the seat and the moves after the first since for a player
+// reconnecting to the game, or ErrNotInGame for anyone else.
+func (g *GameInstance) Rejoin(playerID string, since int) (*RejoinState, error) {
+    r := g.do(command{kind: cmdRejoin, playerID: playerID, since: since})
+    return r.rejoin, r.err
+}
+
+// Snapshot returns a deep copy of the current game state.
+func (g *GameInstance) Snapshot() *models.Game {
+    return g.do(command{kind: cmdSnapshot}).game
+}
+
+// BoardSnapshot returns a copy of the board that the caller may modify.
+func (g *GameInstance) BoardSnapshot() *Board {
+    return g.do(command{kind: cmdBoard}).board
+}
+
+// SeatOf returns the piece number (1 or 2) the player occupies, or 0 if the
+// player is not part of this game.
+func (g *GameInstance) SeatOf(playerID string) int {
+    return g.do(command{kind: cmdSeat, playerID: playerID}).seat
+}
+
+func (g *GameInstance) do(cmd command) reply {
+    cmd.reply = make(chan reply, 1)
+    select {
+    case g.commands <- cmd:
+        return <-cmd.reply
+    case <-g.done:
+        return reply{err: ErrGameClosed}
+    }
+}
+
+func (g *GameInstance) run() {
+    defer g.updates.close()
+    g.armTimer()
+    g.persist()
+    if g.restored {
+        g.publish(UpdateRestored, nil, ResultContinue)
     }
 
-    if playerNum != g.CurrentTurn {
-        return -1, false, "not your turn"
+    for {
+        select {
+        case cmd := <-g.commands:
+            cmd.reply <- g.handle(cmd)
+        case <-g.done:
+            if g.timer != nil {
+                g.timer.Stop()
+            }
+            return
+        }
+    }
+}
+
+func (g *GameInstance) dispatch(listener func(*GameInstance, Update)) {
+    for {
+        batch, ok := g.updates.next()
+        if !ok {
+            return
+        }
+        for _, update := range batch {
+            if listener != nil {
+                listener(g, update)
+            }
+        }
+    }
+}
+
+func (g *GameInstance) handle(cmd command) reply {
+    switch cmd.kind {
+    case cmdMove:
+        return reply{err: g.applyMove(cmd.column, cmd.playerNum)}
+    case cmdResign:
+        return reply{err: g.applyResign(cmd.playerNum)}
+    case cmdTimeout:
+        g.applyTimeout(cmd.ply)
+        return reply{}
+    case cmdAbandon:
+        return reply{err: g.applyAbandon()}
+    case cmdRejoin:
+        seat := SeatOf(g.state, cmd.playerID)
+        if seat == 0 {
+            return reply{err: ErrNotInGame}
+        }
+        return reply{rejoin: &RejoinState{
+            Seat:   seat,
+            Game:   snapshot(g.state),
+            Missed: movesSince(g.state.Moves, cmd.since),
+        }}
+    case cmdSnapshot:
+        return reply{game: snapshot(g.state)}
+    case cmdBoard:
+        return reply{board: g.board.Clone()}
+    case cmdSeat:
+        return reply{seat: SeatOf(g.state, cmd.playerID)}
+    case cmdRequestTakeback:
+        return reply{err: g.applyTakebackRequest(cmd.playerNum)}
+    case cmdRespondTakeback:
+        return reply{err: g.applyTakebackResponse(cmd.playerNum, cmd.accept)}
+    case cmdSwap:
+        return reply{err: g.applySwap(cmd.playerNum)}
+    case cmdPowerUp:
+        return reply{err: g.applyPowerUp(cmd.powerUp, cmd.column, cmd.playerNum)}
+    }
+    return reply{}
+}
+
+func (g *GameInstance) applyMove(col int, playerNum int) error {
+    playerNum, seat, err := g.checkTurn(playerNum)
+    if err != nil {
+        return err
     }
 
     row, ok := g.board.MakeMove(col, playerNum)
     if !ok {
-        return -1, false, "invalid move"
+        return g.columnError(col)
+    }
+
+    winner := 0
+    if g.board.CheckWin(row, col, playerNum) {
+        winner = playerNum
+    }
+
+    g.completeMove(models.Move{
+        GameID: g.ID,
+        Column: col,
+        Row:    row,
+        Player: playerNum,
+        Seat:   seat,
+    }, winner)
+    return nil
+}
+
+// columnError explains why a piece could not be dropped into col.
+func (g *GameInstance) columnError(col int) error {
+    if col < 0 || col >= g.board.Cols() {
+        return ErrInvalidColumn.With(map[string]interface{}{"column": col, "columns": g.board.Cols()})
+    }
+    return ErrColumnFull.With(map[string]interface{}{"column": col})
+}
+
+// notPlaying reports the game's status to a player acting after it ended or
+// before it began.
+func (g *GameInstance) notPlaying() error {
+    return ErrGameNotPlaying.With(map[string]interface{}{"status": g.state.Status})
+}
+
+// notYourTurn tells a player whose turn it is instead.
+func (g *GameInstance) notYourTurn() error {
+    details := map[string]interface{}{"current_turn": g.state.CurrentTurn}
+    if g.state.Settings.Teams {
+        details["turn_seat"] = g.state.TurnSeat
+    }
+    return ErrNotYourTurn.With(details)
+}
+
+// checkTurn checks that the game is on and that playerNum is to move. It
+// returns the colour to play and, in team games, the seat moving for it.
+func (g *GameInstance) checkTurn(playerNum int) (int, int, error) {
+    if g.state.Status != models.StatusPlaying {
+        return 0, 0, g.notPlaying()
+    }
+
+    // In team games the caller passes a seat, which plays its team's colour
+    seat := 0
+    if g.state.Settings.Teams {
+        seat = playerNum
+        colour, err := g.teamSeat(seat)
+        if err != nil {
+            return 0, 0, err
+        }
+        playerNum = colour
+    }
+
+    if playerNum != g.state.CurrentTurn {
+        return 0, 0, g.notYourTurn()
     }
+    return playerNum, seat, nil
+}
+
+// completeMove records a move already made on the board and ends the game or
+// passes the turn. winner is the colour that connected four, or 0.
+func (g *GameInstance) completeMove(move models.Move, winner int) {
+    g.state.Board = g.board.GetGrid()
+    g.state.Moves = append(g.state.Moves, move)
+    g.state.TakebackRequest = 0
+    g.state.UpdatedAt = time.Now()
 
-    g.Board = g.board.GetGrid()
-    g.UpdatedAt = time.Now()
+    ffa := isFFA(g.state)
 
     // Check for win
-    if g.board.CheckWin(row, col, playerNum) {
-        g.Status = models.StatusFinished
-        if playerNum == 1 {
-            g.Winner = g.Player1
-        } else {
-            g.Winner = g.Player2
+    if winner != 0 {
+        if ffa {
+            g.place(winner, 1)
+            g.placeRemaining(2)
         }
-        now := time.Now()
-        g.FinishedAt = &now
-        return row, true, "win"
+        g.state.WinningTeam = g.winningTeam(winner)
+        g.finish(g.playerBySeat(winner), ResultWin)
+        g.persist()
+        g.publish(UpdateMove, &move, ResultWin)
+        g.publish(UpdateEnd, nil, ResultWin)
+        return
     }
 
     // Check for draw
     if g.board.IsFull() {
-        g.Status = models.StatusFinished
-        now := time.Now()
-        g.FinishedAt = &now
-        return row, true, "draw"
+        if ffa {
+            g.placeRemaining(1)
+        }
+        g.finish(nil, ResultDraw)
+        g.persist()
+        g.publish(UpdateMove, &move, ResultDraw)
+        g.publish(UpdateEnd, nil, ResultDraw)
+        return
     }
 
     // Switch turn
-    if g.CurrentTurn == 1 {
-        g.CurrentTurn = 2
+    if ffa {
+        g.state.CurrentTurn = g.nextSeat(move.Player)
+    } else if g.state.CurrentTurn == 1 {
+        g.state.CurrentTurn = 2
     } else {
-        g.CurrentTurn = 1
+        g.state.CurrentTurn = 1
+    }
+    if move.Seat != 0 {
+        g.state.TurnSeat = move.Seat%SeatCount(g.state) + 1
+    }
+    g.armTimer()
+    g.persist()
+
+    g.publish(UpdateMove, &move, ResultContinue)
+}
+
+func (g *GameInstance) applyResign(playerNum int) error {
+    if g.state.Status != models.StatusPlaying {
+        return g.notPlaying()
+    }
+    if playerNum < 1 || playerNum > SeatCount(g.state) {
+        return ErrNotInGame
+    }
+    if isFFA(g.state) {
+        if g.isOut(playerNum) {
+            return ErrEliminated
+        }
+        g.eliminate(playerNum, ResultResign)
+        return nil
+    }
+    // A teammate resigns for the whole team
+    if g.state.Settings.Teams {
+        playerNum = TeamOf(playerNum)
+    }
+
+    g.state.WinningTeam = g.winningTeam(3 - playerNum)
+    g.finish(g.playerBySeat(3-playerNum), ResultResign)
+    g.persist()
+    g.publish(UpdateEnd, nil, ResultResign)
+    return nil
+}
+
+// applyTimeout ends the game if the player to move has not moved since the
+// timer for ply was armed.
+func (g *GameInstance) applyTimeout(ply int) {
+    if g.state.Status != models.StatusPlaying || len(g.state.Moves) != ply {
+        return
+    }
+    if isFFA(g.state) {
+        g.eliminate(g.state.CurrentTurn, ResultTimeout)
+        return
+    }
+
+    g.state.WinningTeam = g.winningTeam(3 - g.state.CurrentTurn)
+    g.finish(g.playerBySeat(3-g.state.CurrentTurn), ResultTimeout)
+    g.persist()
+    g.publish(UpdateEnd, nil, ResultTimeout)
+}
+
+func (g *GameInstance) applyAbandon() error {
+    if g.state.Status != models.StatusWaiting {
+        return g.notPlaying()
+    }
+
+    g.finish(nil, ResultAbandoned)
+    g.state.Status = models.StatusAbandoned
+    g.persist()
+    g.publish(UpdateEnd, nil, ResultAbandoned)
+    return nil
+}
+
+func (g *GameInstance) finish(winner *models.Player, reason string) {
+    now := time.Now()
+    g.state.Status = models.StatusFinished
+    g.state.Winner = winner
+    g.state.EndReason = reason
+    g.state.FinishedAt = &now
+    g.state.UpdatedAt = now
+    g.state.TurnDeadline = nil
+    g.state.TakebackRequest = 0
+    if g.timer != nil {
+        g.timer.Stop()
+    }
+}
+
+func (g *GameInstance) armTimer() {
+    if g.timer != nil {
+        g.timer.Stop()
+    }
+    if g.state.Status != models.StatusPlaying || g.TurnTimeout <= 0 {
+        return
+    }
+
+    deadline := time.Now().Add(g.TurnTimeout)
+    g.state.TurnDeadline = &deadline
+
+    ply := len(g.state.Moves)
+    g.timer = time.AfterFunc(g.TurnTimeout, func() {
+        select {
+        case g.commands <- command{kind: cmdTimeout, ply: ply, reply: make(chan reply, 1)}:
+        case <-g.done:
+        }
+    })
+}
+
+// persist writes the current state to the store before anyone is told about
+// it, so a crash never loses a move that players have already seen.
+func (g *GameInstance) persist() {
+    if g.store == nil {
+        return
+    }
+    if err := g.store.SaveActiveGame(g.state); err != nil {
+        log.Printf("Failed to persist game %s: %v", g.ID, err)
+    }
+}
+
+func (g *GameInstance) publish(kind string, move *models.Move, result string) {
+    g.publishUpdate(Update{
+        Type:   kind,
+        Move:   move,
+        Result: result,
+    })
+}
+
+// publishUpdate attaches a fresh snapshot to the update and queues it.
+func (g *GameInstance) publishUpdate(update Update) {
+    update.Game = snapshot(g.state)
+    g.updates.push(update)
+}
+
+// updateQueue carries updates from the game's goroutine to the listener. It
+// has no limit, so a slow listener never holds up the game.
+type updateQueue struct {
+    mu      sync.Mutex
+    pending []Update
+    closed  bool
+    // ready is signalled whenever pending gains an update or the queue is
+    // closed.
+    ready chan struct{}
+}
+
+func newUpdateQueue() *updateQueue {
+    return &updateQueue{ready: make(chan struct{}, 1)}
+}
+
+func (q *updateQueue) push(update Update) {
+    q.mu.Lock()
+    q.pending = append(q.pending, update)
+    q.mu.Unlock()
+    q.signal()
+}
+
+// close ends the queue once the updates already in it have been taken.
+func (q *updateQueue) close() {
+    q.mu.Lock()
+    q.closed = true
+    q.mu.Unlock()
+    q.signal()
+}
+
+func (q *updateQueue) signal() {
+    select {
+    case q.ready <- struct{}{}:
+    default:
+    }
+}
+
+// next waits for updates and returns all those queued, oldest first, or
+// false once the queue is closed and empty.
+func (q *updateQueue) next() ([]Update, bool) {
+    for {
+        q.mu.Lock()
+        if len(q.pending) > 0 {
+            batch := q.pending
+            q.pending = nil
+            q.mu.Unlock()
+            return batch, true
+        }
+        closed := q.closed
+        q.mu.Unlock()
+        if closed {
+            return nil, false
+        }
+        <-q.ready
     }
+}
 
-    return row, true, "continue"
+// winningTeam returns the colour as the winning team in team games and 0
+// otherwise.
+func (g *GameInstance) winningTeam(colour int) int {
+    if g.state.Settings.Teams {
+        return colour
+    }
+    return 0
 }
 
-func (g *GameInstance) GetBoard() *Board {
-    return g.board
-}
\ No newline at end of file
+// playerBySeat returns the player in a seat. In team games the first seats
+// double as the team captains, so a colour finds the captain of its team.
+func (g *GameInstance) playerBySeat(seat int) *models.Player {
+    if len(g.state.Seats) > 0 {
+        return g.state.Seats[seat-1]
+    }
+    if seat == 1 {
+        return g.state.Player1
+    }
+    return g.state.Player2
+}
+
+// SeatOf returns the piece number the player occupies in game, or 0 if the
+// player is not part of it.
+func SeatOf(game *models.Game, playerID string) int {
+    for i, player := range Players(game) {
+        if player.ID == playerID {
+            return i + 1
+        }
+    }
+    return 0
+}
+
+func movesSince(moves []models.Move, n int) []models.Move {
+    if n < 0 || n > len(moves) {
+        n = 0
+    }
+    missed := make([]models.Move, len(moves)-n)
+    copy(missed, moves[n:])
+    return missed
+}
+
+func snapshot(g *models.Game) *models.Game {
+    c := *g
+    c.Player1 = copyPlayer(g.Player1)
+    c.Player2 = copyPlayer(g.Player2)
+    c.Winner = copyPlayer(g.Winner)
+
+    if g.Seats != nil {
+        c.Seats = make([]*models.Player, len(g.Seats))
+        for i, player := range g.Seats {
+            c.Seats[i] = copyPlayer(player)
+        }
+        // Keep the mirrored seats pointing into the copy
+        c.Player1, c.Player2 = c.Seats[0], c.Seats[1]
+    }
+    c.Placements = append([]models.Placement(nil), g.Placements...)
+
+    if g.Inventory != nil {
+        c.Inventory = make([]models.Inventory, len(g.Inventory))
+        for i, inventory := range g.Inventory {
+            c.Inventory[i] = models.Inventory{}
+            for kind, count := range inventory {
+                c.Inventory[i][kind] = count
+            }
+        }
+    }
+
+    c.Board = make([][]int, len(g.Board))
+    for i := range g.Board {
+        c.Board[i] = append([]int(nil), g.Board[i]...)
+    }
+    c.Moves = append([]models.Move{}, g.Moves...)
+
+    if g.FinishedAt != nil {
+        t := *g.FinishedAt
+        c.FinishedAt = &t
+    }
+    if g.TurnDeadline != nil {
+        t := *g.TurnDeadline
+        c.TurnDeadline = &t
+    }
+    return &c
+}
+
+func copyPlayer(p *models.Player) *models.Player {
+    if p == nil {
+        return nil
+    }
+    c := *p
+    return &c
+}
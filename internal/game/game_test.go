@@ -0,0 +1,67 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// newTestTwoPlayerGame starts a game between alice (p1) and bob (p2) and
+// plays the moves of a move string.
+func newTestTwoPlayerGame(t *testing.T, settings models.GameSettings, moves string) *GameInstance {
+	t.Helper()
+	g, err := NewGameWithSettings(&models.Player{ID: "p1", Username: "alice", Piece: 1}, false, settings)
+	if err != nil {
+		t.Fatalf("NewGameWithSettings: %v", err)
+	}
+	g.AddPlayer2(&models.Player{ID: "p2", Username: "bob", Piece: 2})
+	g.Start(nil)
+	t.Cleanup(g.Close)
+
+	for _, move := range mustMoves(t, moves) {
+		if err := g.MakeMove(move.Column, move.Player); err != nil {
+			t.Fatalf("MakeMove(%d, %d): %v", move.Column+1, move.Player, err)
+		}
+	}
+	return g
+}
+
+func TestRejoin(t *testing.T) {
+	tests := []struct {
+		name       string
+		playerID   string
+		since      int
+		wantSeat   int
+		wantMissed string
+		wantErr    error
+	}{
+		{"from the start", "p1", 0, 1, "445", nil},
+		{"after a move", "p2", 2, 2, "5", nil},
+		{"up to date", "p1", 3, 1, "", nil},
+		{"count out of range", "p2", 9, 2, "445", nil},
+		{"not a player", "p3", 0, 0, "", ErrNotInGame},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestTwoPlayerGame(t, models.GameSettings{}, "445")
+
+			state, err := g.Rejoin(tt.playerID, tt.since)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || state != nil {
+					t.Errorf("Rejoin = %+v, %v; want %v", state, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rejoin: %v", err)
+			}
+			if state.Seat != tt.wantSeat {
+				t.Errorf("seat = %d, want %d", state.Seat, tt.wantSeat)
+			}
+			if got := FormatMoveString(state.Missed); got != tt.wantMissed {
+				t.Errorf("missed moves = %q, want %q", got, tt.wantMissed)
+			}
+		})
+	}
+}
@@ -1,24 +1,131 @@
 package game
 
 import (
+    "context"
+    "log"
     "sync"
+    "time"
+    "github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
 )
 
+// ManagerConfig controls how long games are kept in memory.
+type ManagerConfig struct {
+    // FinishedRetention is how long a finished game stays available for
+    // rejoins before it is evicted.
+    FinishedRetention time.Duration
+    // WaitingTimeout is how long a game may wait for its second player.
+    WaitingTimeout time.Duration
+    // MaxGames caps the number of unfinished games. Zero means no cap.
+    MaxGames int
+    // SweepInterval is how often the sweeper runs.
+    SweepInterval time.Duration
+}
+
+func DefaultManagerConfig() ManagerConfig {
+    return ManagerConfig{
+        FinishedRetention: 10 * time.Minute,
+        WaitingTimeout:    5 * time.Minute,
+        MaxGames:          1000,
+        SweepInterval:     time.Minute,
+    }
+}
+
 type Manager struct {
-    games map[string]*GameInstance
-    mu    sync.RWMutex
+    games    map[string]*GameInstance
+    // unfinished holds the IDs of the games that are waiting or being
+    // played, which MaxGames caps.
+    unfinished map[string]bool
+    listener func(*GameInstance, Update)
+    config   ManagerConfig
+    store    Store
+    mu       sync.RWMutex
 }
 
-func NewManager() *Manager {
+// NewManager creates a manager. store may be nil, in which case games live
+// only in memory.
+func NewManager(config ManagerConfig, store Store) *Manager {
     return &Manager{
-        games: make(map[string]*GameInstance),
+        games:      make(map[string]*GameInstance),
+        unfinished: make(map[string]bool),
+        config:     config,
+        store:  store,
     }
 }
 
-func (m *Manager) AddGame(game *GameInstance) {
+// OnUpdate registers the function that receives updates from every game the
+// manager starts. It must be set before games are added.
+func (m *Manager) OnUpdate(listener func(*GameInstance, Update)) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.listener = listener
+}
+
+// AddGame registers the game and starts its goroutine. It fails with
+// ErrTooManyGames once MaxGames unfinished games are running.
+func (m *Manager) AddGame(game *GameInstance) error {
     m.mu.Lock()
     defer m.mu.Unlock()
+
+    if m.config.MaxGames > 0 && len(m.unfinished) >= m.config.MaxGames {
+        return ErrTooManyGames
+    }
+    m.startLocked(game)
+    return nil
+}
+
+func (m *Manager) startLocked(game *GameInstance) {
     m.games[game.ID] = game
+    m.unfinished[game.ID] = true
+    game.store = m.store
+    game.Start(m.tracked(m.listener))
+}
+
+// tracked wraps a game's listener so the manager sees the game end.
+func (m *Manager) tracked(listener func(*GameInstance, Update)) func(*GameInstance, Update) {
+    return func(game *GameInstance, update Update) {
+        if update.Game != nil && update.Game.Status != models.StatusWaiting && update.Game.Status != models.StatusPlaying {
+            m.mu.Lock()
+            delete(m.unfinished, game.ID)
+            m.mu.Unlock()
+        }
+        if listener != nil {
+            listener(game, update)
+        }
+    }
+}
+
+// Recover reloads unfinished games from the store and starts them again.
+// Leftover records of games that had already ended are discarded.
+func (m *Manager) Recover() (int, error) {
+    if m.store == nil {
+        return 0, nil
+    }
+
+    states, err := m.store.LoadActiveGames()
+    if err != nil {
+        return 0, err
+    }
+
+    recovered := 0
+    for _, state := range states {
+        if state.Status != models.StatusPlaying && state.Status != models.StatusWaiting {
+            m.store.DeleteActiveGame(state.ID)
+            continue
+        }
+
+        game, err := Restore(state)
+        if err != nil {
+            log.Printf("Skipping unrecoverable game: %v", err)
+            continue
+        }
+
+        m.mu.Lock()
+        m.startLocked(game)
+        m.mu.Unlock()
+        recovered++
+    }
+
+    return recovered, nil
 }
 
 func (m *Manager) GetGame(gameID string) (*GameInstance, bool) {
@@ -30,17 +137,91 @@ func (m *Manager) GetGame(gameID string) (*GameInstance, bool) {
 
 func (m *Manager) RemoveGame(gameID string) {
     m.mu.Lock()
-    defer m.mu.Unlock()
-    delete(m.games, gameID)
+    game, ok := m.games[gameID]
+    if ok {
+        game.Close()
+        delete(m.games, gameID)
+        delete(m.unfinished, gameID)
+    }
+    m.mu.Unlock()
+
+    if ok && m.store != nil {
+        if err := m.store.DeleteActiveGame(gameID); err != nil {
+            log.Printf("Failed to delete stored game %s: %v", gameID, err)
+        }
+    }
 }
 
 func (m *Manager) GetAllActiveGames() []*GameInstance {
     m.mu.RLock()
     defer m.mu.RUnlock()
-    
+
     games := make([]*GameInstance, 0, len(m.games))
     for _, game := range m.games {
         games = append(games, game)
     }
     return games
-}
\ No newline at end of file
+}
+
+// CountByStatus reports how many games the manager holds in each status.
+func (m *Manager) CountByStatus() map[models.GameStatus]int {
+    counts := map[models.GameStatus]int{
+        models.StatusWaiting:   0,
+        models.StatusPlaying:   0,
+        models.StatusFinished:  0,
+        models.StatusAbandoned: 0,
+    }
+    for _, game := range m.GetAllActiveGames() {
+        if snapshot := game.Snapshot(); snapshot != nil {
+            counts[snapshot.Status]++
+        }
+    }
+    return counts
+}
+
+// Run sweeps expired games every SweepInterval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+    ticker := time.NewTicker(m.config.SweepInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ticker.C:
+            m.sweep(time.Now())
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+// sweep evicts finished games past their retention window and abandons
+// waiting games that never filled.
+func (m *Manager) sweep(now time.Time) {
+    evicted, abandoned := 0, 0
+
+    for _, game := range m.GetAllActiveGames() {
+        snapshot := game.Snapshot()
+        if snapshot == nil {
+            m.RemoveGame(game.ID)
+            evicted++
+            continue
+        }
+
+        switch snapshot.Status {
+        case models.StatusWaiting:
+            if now.Sub(snapshot.CreatedAt) > m.config.WaitingTimeout {
+                game.Abandon()
+                abandoned++
+            }
+        case models.StatusFinished, models.StatusAbandoned:
+            if snapshot.FinishedAt != nil && now.Sub(*snapshot.FinishedAt) > m.config.FinishedRetention {
+                m.RemoveGame(game.ID)
+                evicted++
+            }
+        }
+    }
+
+    if evicted > 0 || abandoned > 0 {
+        log.Printf("Game sweeper: evicted %d, abandoned %d", evicted, abandoned)
+    }
+}
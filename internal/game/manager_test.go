@@ -0,0 +1,54 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func newTestBotGame(t *testing.T, id string) *GameInstance {
+	t.Helper()
+	g := NewGame(&models.Player{ID: id, Username: id, Piece: 1}, true)
+	g.AddPlayer2(&models.Player{ID: "bot-" + id, Username: "Bot", Piece: 2})
+	t.Cleanup(g.Close)
+	return g
+}
+
+func TestAddGameCap(t *testing.T) {
+	config := DefaultManagerConfig()
+	config.MaxGames = 2
+	m := NewManager(config, nil)
+
+	first, second := newTestBotGame(t, "p1"), newTestBotGame(t, "p2")
+	for _, g := range []*GameInstance{first, second} {
+		if err := m.AddGame(g); err != nil {
+			t.Fatalf("AddGame: %v", err)
+		}
+	}
+	if err := m.AddGame(newTestBotGame(t, "p3")); err != ErrTooManyGames {
+		t.Fatalf("AddGame over the cap = %v, want ErrTooManyGames", err)
+	}
+
+	// A finished game no longer counts, once its end has been seen
+	if err := first.Resign(1); err != nil {
+		t.Fatalf("Resign: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err := m.AddGame(newTestBotGame(t, "p4"))
+		if err == nil {
+			break
+		}
+		if err != ErrTooManyGames || time.Now().After(deadline) {
+			t.Fatalf("AddGame after a game finished = %v, want nil", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A removed game frees its place straight away
+	m.RemoveGame(second.ID)
+	if err := m.AddGame(newTestBotGame(t, "p5")); err != nil {
+		t.Errorf("AddGame after a game was removed = %v, want nil", err)
+	}
+}
@@ -0,0 +1,98 @@
+package game
+
+import (
+	"errors"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// NextMatchGame creates the second game of a balanced mini-match: the same
+// opening as the finished first game, with the players in opposite seats.
+// It returns nil when the game is not the first of a mini-match.
+func NextMatchGame(previous *models.Game) (*GameInstance, error) {
+	settings := previous.Settings
+	if settings.Ruleset != models.RulesetBalanced || settings.MatchGame != 1 {
+		return nil, nil
+	}
+	if previous.Player1 == nil || previous.Player2 == nil {
+		return nil, nil
+	}
+	settings.MatchGame = 2
+	swapSettingsSeats(&settings)
+
+	player1 := copyPlayer(previous.Player2)
+	player2 := copyPlayer(previous.Player1)
+	player1.Piece = 1
+	player2.Piece = 2
+
+	next, err := NewGameWithSettings(player1, previous.IsBot, settings)
+	if err != nil {
+		return nil, err
+	}
+	next.AddPlayer2(player2)
+	return next, nil
+}
+
+// MatchScores totals a mini-match by username: a point for a win and half a
+// point each for a draw.
+func MatchScores(records []models.GameRecord) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, record := range records {
+		scores[record.Player1Username] += 0
+		scores[record.Player2Username] += 0
+
+		switch {
+		case record.Status != models.StatusFinished:
+		case record.WinnerUsername != "":
+			scores[record.WinnerUsername]++
+		default:
+			scores[record.Player1Username] += 0.5
+			scores[record.Player2Username] += 0.5
+		}
+	}
+	return scores
+}
+
+// NewRematch creates a new game between the players of a finished
+// two-player game with their colours swapped. The settings carry over,
+// except that a balanced game starts a new mini-match from a new opening.
+// A handicap moves with the player it was given to; see swapSettingsSeats.
+func NewRematch(previous *models.Game) (*GameInstance, error) {
+	if previous.Player1 == nil || previous.Player2 == nil || SeatCount(previous) != 2 {
+		return nil, invalidSettings(errors.New("only two-player games can be rematched"))
+	}
+
+	settings := previous.Settings
+	if settings.Ruleset == models.RulesetBalanced {
+		settings.Opening = ""
+		settings.MatchID = ""
+		settings.MatchGame = 0
+	}
+	swapSettingsSeats(&settings)
+
+	player1 := copyPlayer(previous.Player2)
+	player2 := copyPlayer(previous.Player1)
+	player1.Piece = 1
+	player2.Piece = 2
+
+	next, err := NewGameWithSettings(player1, previous.IsBot, settings)
+	if err != nil {
+		return nil, err
+	}
+	next.AddPlayer2(player2)
+	return next, nil
+}
+
+// swapSettingsSeats adjusts settings for a game in which the two players
+// have changed seats. A handicap belongs to a player, so it follows them to
+// their new seat. FirstTurn belongs to the seat and is left alone: the
+// player who moved first last game moves second this time, just as swapping
+// colours does for a standard start.
+func swapSettingsSeats(settings *models.GameSettings) {
+	if handicap := settings.Handicap; handicap != nil {
+		settings.Handicap = &models.Handicap{
+			Seat:    3 - handicap.Seat,
+			Columns: append([]int(nil), handicap.Columns...),
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func TestNewRematch(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.GameSettings
+		// wantFEN is the rematch's start position, where bob has seat 1
+		// and alice seat 2.
+		wantFEN       string
+		wantFirstTurn int
+	}{
+		{
+			name:     "handicap follows the player",
+			settings: models.GameSettings{Handicap: &models.Handicap{Seat: 2, Columns: []int{0, 6}}},
+			wantFEN:  "7/7/7/7/7/x5x x",
+		},
+		{
+			name:          "first turn stays with the seat",
+			settings:      models.GameSettings{Handicap: &models.Handicap{Seat: 1, Columns: []int{3}}, FirstTurn: 2},
+			wantFEN:       "7/7/7/7/7/3o3 o",
+			wantFirstTurn: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alice := &models.Player{ID: "p1", Username: "alice", Piece: 1}
+			bob := &models.Player{ID: "p2", Username: "bob", Piece: 2}
+			first, err := NewGameWithSettings(alice, false, tt.settings)
+			if err != nil {
+				t.Fatalf("NewGameWithSettings: %v", err)
+			}
+			first.AddPlayer2(bob)
+			first.Start(nil)
+			t.Cleanup(first.Close)
+			previous := first.Snapshot()
+			before := *previous.Settings.Handicap
+
+			rematch, err := NewRematch(previous)
+			if err != nil {
+				t.Fatalf("NewRematch: %v", err)
+			}
+			rematch.Start(nil)
+			t.Cleanup(rematch.Close)
+			state := rematch.Snapshot()
+
+			if state.Player1.ID != bob.ID || state.Player2.ID != alice.ID {
+				t.Errorf("rematch seats are %s and %s, want bob and alice", state.Player1.Username, state.Player2.Username)
+			}
+			if state.Settings.Handicap == nil || state.Settings.Handicap.Seat != 3-before.Seat {
+				t.Errorf("rematch handicap = %+v, want it on seat %d", state.Settings.Handicap, 3-before.Seat)
+			}
+			if state.Settings.FirstTurn != tt.wantFirstTurn {
+				t.Errorf("rematch first turn = %d, want %d", state.Settings.FirstTurn, tt.wantFirstTurn)
+			}
+			if state.StartFEN != tt.wantFEN {
+				t.Errorf("rematch start = %q, want %q", state.StartFEN, tt.wantFEN)
+			}
+			if !reflect.DeepEqual(*previous.Settings.Handicap, before) {
+				t.Errorf("the finished game's handicap changed to %+v", previous.Settings.Handicap)
+			}
+		})
+	}
+}
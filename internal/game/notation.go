@@ -0,0 +1,319 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Three text formats are supported:
+//
+// A move string lists the columns played, 1-indexed from the left, starting
+// with player 1: "4453" is player 1 in column 4, player 2 in column 4, and
+// so on.
+//
+// A FEN-style string lists the rows from top to bottom separated by '/',
+// with 'x' for player 1, 'o' for player 2 and a digit for a run of empty
+// cells, followed by the side to move: "7/7/7/7/7/3x3 o".
+//
+// A board string writes a board of any size one character per cell, rows
+// from top to bottom separated by '/': '.' for an empty cell, the seat
+// number for a piece and '#' for a wall: "......./......./...1...". It is
+// how compact clients are sent the board.
+
+const (
+	fenPlayer1 = 'x'
+	fenPlayer2 = 'o'
+)
+
+// ParseMoveString plays a move string from the empty board and returns the
+// moves with their landing rows. Moves after the game is decided are
+// rejected.
+func ParseMoveString(s string) ([]models.Move, error) {
+	board := NewBoard()
+	moves := make([]models.Move, 0, len(s))
+
+	player := 1
+	decided := false
+	for _, ch := range s {
+		ply := len(moves) + 1
+		if decided {
+			return nil, fmt.Errorf("move %d: game is already over", ply)
+		}
+		if ch < '1' || ch > rune('0'+Cols) {
+			return nil, fmt.Errorf("move %d: %q is not a column between 1 and %d", ply, ch, Cols)
+		}
+
+		col := int(ch - '1')
+		row, ok := board.MakeMove(col, player)
+		if !ok {
+			return nil, fmt.Errorf("move %d: column %d is full", ply, col+1)
+		}
+		moves = append(moves, models.Move{Column: col, Row: row, Player: player})
+
+		decided = board.CheckWin(row, col, player) || board.IsFull()
+		player = 3 - player
+	}
+
+	return moves, nil
+}
+
+// FormatMoveString writes moves as a 1-indexed move string. Moves that were
+// taken back are left out.
+func FormatMoveString(moves []models.Move) string {
+	var sb strings.Builder
+	for _, move := range standingMoves(moves) {
+		sb.WriteByte(byte('1' + move.Column))
+	}
+	return sb.String()
+}
+
+// EncodeFEN writes the board and the side to move as a FEN-style string.
+func EncodeFEN(board *Board, toMove int) string {
+	var sb strings.Builder
+	for row := 0; row < Rows; row++ {
+		if row > 0 {
+			sb.WriteByte('/')
+		}
+
+		empty := 0
+		for col := 0; col < Cols; col++ {
+			cell := board.grid[row][col]
+			if cell == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			sb.WriteByte(fenPiece(cell))
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+	}
+
+	sb.WriteByte(' ')
+	sb.WriteByte(fenPiece(toMove))
+	return sb.String()
+}
+
+// EncodeBoard writes a board, given as rows of cells, as a board string.
+func EncodeBoard(grid [][]int) string {
+	var sb strings.Builder
+	for row, cells := range grid {
+		if row > 0 {
+			sb.WriteByte('/')
+		}
+		for _, cell := range cells {
+			switch {
+			case cell == 0:
+				sb.WriteByte('.')
+			case cell == Wall:
+				sb.WriteByte('#')
+			default:
+				sb.WriteString(strconv.Itoa(cell))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// DecodeFEN parses a FEN-style string and checks that the position could
+// arise in a real game: no floating pieces, piece counts consistent with the
+// side to move, at most the player who just moved having four in a row, and
+// some order of alternating moves that builds the board without the game
+// ending early.
+func DecodeFEN(s string) (*Board, int, error) {
+	placement, side, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return nil, 0, fmt.Errorf("missing side to move")
+	}
+
+	toMove, err := fenSeat(side)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	board, err := decodePlacement(placement)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := validatePosition(board, toMove); err != nil {
+		return nil, 0, err
+	}
+
+	return board, toMove, nil
+}
+
+func decodePlacement(placement string) (*Board, error) {
+	rows := strings.Split(placement, "/")
+	if len(rows) != Rows {
+		return nil, fmt.Errorf("expected %d rows, got %d", Rows, len(rows))
+	}
+
+	board := NewBoard()
+	for r, text := range rows {
+		col := 0
+		for _, ch := range text {
+			switch {
+			case ch >= '1' && ch <= '9':
+				col += int(ch - '0')
+			case ch == fenPlayer1 || ch == fenPlayer2:
+				if col < Cols {
+					board.grid[r][col], _ = fenSeat(string(ch))
+				}
+				col++
+			default:
+				return nil, fmt.Errorf("row %d, column %d: unexpected %q", r+1, col+1, ch)
+			}
+		}
+		if col != Cols {
+			return nil, fmt.Errorf("row %d: expected %d cells, got %d", r+1, Cols, col)
+		}
+	}
+
+	return board, nil
+}
+
+func validatePosition(board *Board, toMove int) error {
+	if err := checkFloating(board); err != nil {
+		return err
+	}
+
+	count := [3]int{}
+	for row := 0; row < Rows; row++ {
+		for col := 0; col < Cols; col++ {
+			count[board.grid[row][col]]++
+		}
+	}
+
+	expected := 1
+	switch count[1] - count[2] {
+	case 0:
+	case 1:
+		expected = 2
+	default:
+		return fmt.Errorf("player 1 has %d pieces and player 2 has %d", count[1], count[2])
+	}
+	if toMove != expected {
+		return fmt.Errorf("piece counts say player %d is to move", expected)
+	}
+
+	if board.HasFour(toMove) {
+		return fmt.Errorf("player %d already has four in a row but is to move", toMove)
+	}
+
+	return checkReachable(board, toMove)
+}
+
+// checkReachable takes the position back one move at a time, lifting the top
+// piece of whoever moved last, until the board is empty. If the player who
+// just moved has four in a row, the first piece lifted must break every one
+// of them: the game ends on the move that first makes four.
+//
+// Which positions are left depends only on how many pieces stand in each
+// column, so dead ends are remembered by column heights.
+func checkReachable(board *Board, toMove int) error {
+	var heights [Cols]int
+	for col := 0; col < Cols; col++ {
+		for row := Rows - 1; row >= 0 && board.grid[row][col] != 0; row-- {
+			heights[col]++
+		}
+	}
+
+	last := 3 - toMove
+	won := board.HasFour(last)
+	deadEnds := make(map[[Cols]int]bool)
+	for col := 0; col < Cols; col++ {
+		if heights[col] == 0 {
+			continue
+		}
+		top := Rows - heights[col]
+		if board.grid[top][col] != last {
+			continue
+		}
+		if won {
+			board.grid[top][col] = 0
+			broken := !board.HasFour(last)
+			board.grid[top][col] = last
+			if !broken {
+				continue
+			}
+		}
+
+		heights[col]--
+		ok := unwindPosition(board, heights, toMove, deadEnds)
+		heights[col]++
+		if ok {
+			return nil
+		}
+	}
+
+	if won {
+		return fmt.Errorf("player %d has four in a row that no single last move completes", last)
+	}
+	if heights == [Cols]int{} {
+		return nil
+	}
+	return fmt.Errorf("no order of moves reaches this position")
+}
+
+// unwindPosition reports whether the pieces below heights can be lifted off
+// one at a time, player's first, alternating down to the empty board.
+func unwindPosition(board *Board, heights [Cols]int, player int, deadEnds map[[Cols]int]bool) bool {
+	if heights == [Cols]int{} {
+		return true
+	}
+	if deadEnds[heights] {
+		return false
+	}
+
+	for col := 0; col < Cols; col++ {
+		if heights[col] == 0 || board.grid[Rows-heights[col]][col] != player {
+			continue
+		}
+		heights[col]--
+		ok := unwindPosition(board, heights, 3-player, deadEnds)
+		heights[col]++
+		if ok {
+			return true
+		}
+	}
+
+	deadEnds[heights] = true
+	return false
+}
+
+// checkFloating reports a piece with an empty cell beneath it.
+func checkFloating(board *Board) error {
+	for row := 0; row < Rows-1; row++ {
+		for col := 0; col < Cols; col++ {
+			if board.grid[row][col] != 0 && board.grid[row+1][col] == 0 {
+				return fmt.Errorf("piece at row %d, column %d is floating", row+1, col+1)
+			}
+		}
+	}
+	return nil
+}
+
+func fenPiece(player int) byte {
+	if player == 2 {
+		return fenPlayer2
+	}
+	return fenPlayer1
+}
+
+func fenSeat(s string) (int, error) {
+	switch s {
+	case string(fenPlayer1):
+		return 1, nil
+	case string(fenPlayer2):
+		return 2, nil
+	}
+	return 0, fmt.Errorf("side to move must be %q or %q, got %q", fenPlayer1, fenPlayer2, s)
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMoveStringRoundTrip(t *testing.T) {
+	tests := []string{"", "4", "4453", "1212121", "444444", "333333"}
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			moves, err := ParseMoveString(s)
+			if err != nil {
+				t.Fatalf("ParseMoveString(%q): %v", s, err)
+			}
+			if got := FormatMoveString(moves); got != s {
+				t.Errorf("FormatMoveString = %q, want %q", got, s)
+			}
+		})
+	}
+}
+
+func TestParseMoveStringRejects(t *testing.T) {
+	tests := []struct {
+		moves   string
+		wantErr string
+	}{
+		{"0", "not a column"},
+		{"48", "not a column"},
+		{"4a", "not a column"},
+		{"4444444", "column 4 is full"},
+		{"12121212", "already over"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.moves, func(t *testing.T) {
+			_, err := ParseMoveString(tt.moves)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseMoveString(%q) error = %v, want one containing %q", tt.moves, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFENRoundTrip(t *testing.T) {
+	tests := []struct {
+		moves string
+		fen   string
+	}{
+		{"", "7/7/7/7/7/7 x"},
+		{"4", "7/7/7/7/7/3x3 o"},
+		{"4453", "7/7/7/7/3o3/2oxx2 x"},
+		{"1234567", "7/7/7/7/7/xoxoxox o"},
+		{"1212121", "7/7/x6/xo5/xo5/xo5 o"},
+		{"444444333333", "2oo3/2xx3/2oo3/2xx3/2oo3/2xx3 x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.moves, func(t *testing.T) {
+			board, toMove, err := loadStart("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			moves, err := ParseMoveString(tt.moves)
+			if err != nil {
+				t.Fatalf("ParseMoveString(%q): %v", tt.moves, err)
+			}
+			for _, move := range moves {
+				board.MakeMove(move.Column, move.Player)
+				toMove = 3 - move.Player
+			}
+
+			fen := EncodeFEN(board, toMove)
+			if fen != tt.fen {
+				t.Fatalf("EncodeFEN = %q, want %q", fen, tt.fen)
+			}
+			decoded, decodedToMove, err := DecodeFEN(fen)
+			if err != nil {
+				t.Fatalf("DecodeFEN(%q): %v", fen, err)
+			}
+			if again := EncodeFEN(decoded, decodedToMove); again != fen {
+				t.Errorf("EncodeFEN after DecodeFEN = %q, want %q", again, fen)
+			}
+		})
+	}
+}
+
+func TestDecodeFENRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		wantErr string
+	}{
+		{"no side to move", "7/7/7/7/7/7", "missing side to move"},
+		{"bad side to move", "7/7/7/7/7/7 z", "side to move"},
+		{"too few rows", "7/7/7/7/7 x", "expected 6 rows"},
+		{"short row", "7/7/7/7/7/6 x", "expected 7 cells"},
+		{"bad cell", "7/7/7/7/7/3q3 o", "row 6, column 4: unexpected"},
+		{"floating piece", "7/7/7/7/3x3/7 o", "floating"},
+		{"too many for player 1", "7/7/7/7/7/xx5 o", "has 2 pieces"},
+		{"wrong side to move", "7/7/7/7/7/3x3 x", "player 2 is to move"},
+		{"winner to move", "7/7/7/o6/o6/xxxxoo1 x", "already has four"},
+		{"last mover buried", "7/7/7/7/x6/o6 x", "no order of moves"},
+		{"four completed too early", "o6/o6/x6/xx5/xo5/xo5 o", "no single last move"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := DecodeFEN(tt.fen)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecodeFEN(%q) error = %v, want one containing %q", tt.fen, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodeBoard(t *testing.T) {
+	tests := []struct {
+		name string
+		grid [][]int
+		want string
+	}{
+		{"empty", [][]int{{0, 0}, {0, 0}}, "../.."},
+		{"pieces and a wall", [][]int{{0, Wall, 0}, {1, 2, 3}}, ".#./123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeBoard(tt.grid); got != tt.want {
+				t.Errorf("EncodeBoard = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
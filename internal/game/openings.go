@@ -0,0 +1,68 @@
+package game
+
+import "math/rand"
+
+// Opening is a starting position for balanced games, given as a move string
+// from the empty board.
+type Opening struct {
+	ID    string `json:"id"`
+	Moves string `json:"moves"`
+}
+
+// openingBook lists the balanced openings. Each was checked with a perfect
+// play solver and is a draw with best play from both sides, so neither
+// colour starts ahead; the solver's results are in
+// testdata/openings_solved.txt. Positions that are mirror images of one another are
+// listed once. IDs are kept in game records and statistics, so they are
+// never reused: B01 to B13 were an earlier book.
+var openingBook = []Opening{
+	{ID: "B14", Moves: "3333"},
+	{ID: "B15", Moves: "3342"},
+	{ID: "B16", Moves: "3345"},
+	{ID: "B17", Moves: "3324"},
+	{ID: "B18", Moves: "3325"},
+	{ID: "B19", Moves: "3356"},
+	{ID: "B20", Moves: "3364"},
+	{ID: "B21", Moves: "3366"},
+	{ID: "B22", Moves: "3433"},
+	{ID: "B23", Moves: "3444"},
+	{ID: "B24", Moves: "3425"},
+	{ID: "B25", Moves: "3426"},
+	{ID: "B26", Moves: "3452"},
+	{ID: "B27", Moves: "3466"},
+	{ID: "B28", Moves: "3246"},
+	{ID: "B29", Moves: "3222"},
+	{ID: "B30", Moves: "3252"},
+	{ID: "B31", Moves: "3256"},
+	{ID: "B32", Moves: "3533"},
+	{ID: "B33", Moves: "3545"},
+	{ID: "B34", Moves: "3525"},
+	{ID: "B35", Moves: "3555"},
+	{ID: "B36", Moves: "3566"},
+	{ID: "B37", Moves: "3633"},
+	{ID: "B38", Moves: "3663"},
+}
+
+// Openings returns the book openings.
+func Openings() []Opening {
+	return openingBook
+}
+
+// OpeningByID looks up a book opening.
+func OpeningByID(id string) (Opening, bool) {
+	for _, opening := range openingBook {
+		if opening.ID == id {
+			return opening, true
+		}
+	}
+	return Opening{}, false
+}
+
+// RandomOpening picks a book opening at random. It reports false if the book
+// is empty.
+func RandomOpening() (Opening, bool) {
+	if len(openingBook) == 0 {
+		return Opening{}, false
+	}
+	return openingBook[rand.Intn(len(openingBook))], true
+}
@@ -0,0 +1,108 @@
+package game
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Every book opening must be a playable, undecided position, and no two may
+// be the same position or mirror images of each other.
+func TestOpeningBook(t *testing.T) {
+	if len(Openings()) == 0 {
+		t.Fatal("the opening book is empty")
+	}
+
+	ids := map[string]bool{}
+	positions := map[string]string{}
+	for _, opening := range Openings() {
+		if ids[opening.ID] {
+			t.Errorf("opening ID %s is used twice", opening.ID)
+		}
+		ids[opening.ID] = true
+
+		moves, err := ParseMoveString(opening.Moves)
+		if err != nil {
+			t.Errorf("opening %s: %v", opening.ID, err)
+			continue
+		}
+		board, mirror := NewBoard(), NewBoard()
+		for _, move := range moves {
+			board.MakeMove(move.Column, move.Player)
+			mirror.MakeMove(Cols-1-move.Column, move.Player)
+		}
+		if board.HasFour(1) || board.HasFour(2) || board.IsFull() {
+			t.Errorf("opening %s (%s) is already decided", opening.ID, opening.Moves)
+		}
+
+		toMove := 1 + len(moves)%2
+		for _, fen := range []string{EncodeFEN(board, toMove), EncodeFEN(mirror, toMove)} {
+			if other, ok := positions[fen]; ok {
+				t.Errorf("opening %s (%s) is the same position as %s", opening.ID, opening.Moves, other)
+			}
+		}
+		positions[EncodeFEN(board, toMove)] = opening.ID
+	}
+}
+
+// Every book opening must have been solved as a draw. The results come from
+// an external solver and are kept in testdata.
+func TestOpeningBookSolved(t *testing.T) {
+	file, err := os.Open("testdata/openings_solved.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scores := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			t.Fatalf("malformed solver result %q", line)
+		}
+		scores[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opening := range Openings() {
+		score, ok := scores[opening.Moves]
+		switch {
+		case !ok:
+			t.Errorf("opening %s (%s) has no solver result", opening.ID, opening.Moves)
+		case score != "0":
+			t.Errorf("opening %s (%s) scores %s with best play, want 0 (a draw)", opening.ID, opening.Moves, score)
+		}
+	}
+}
+
+func TestNewBalancedGame(t *testing.T) {
+	player := &models.Player{ID: "p1", Username: "alice", Piece: 1}
+	g, err := NewGameWithSettings(player, true, models.GameSettings{Ruleset: models.RulesetBalanced})
+	if err != nil {
+		t.Fatalf("NewGameWithSettings: %v", err)
+	}
+	g.Start(nil)
+	t.Cleanup(g.Close)
+
+	state := g.Snapshot()
+	opening, ok := OpeningByID(state.Settings.Opening)
+	if !ok {
+		t.Fatalf("game started from opening %q, which is not in the book", state.Settings.Opening)
+	}
+	if state.StartFEN == "" {
+		t.Errorf("balanced game from %s has no stored start position", opening.ID)
+	}
+	if len(state.Moves) != 0 {
+		t.Errorf("opening moves were recorded as game moves: %+v", state.Moves)
+	}
+}
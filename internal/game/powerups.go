@@ -0,0 +1,179 @@
+package game
+
+import (
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Wall is the board value of a neutral wall block. It belongs to neither
+// player, so it never counts towards four in a row.
+const Wall = -1
+
+// DefaultInventory is the power-ups each player starts an arcade game with.
+var DefaultInventory = models.Inventory{
+	models.MoveAnvil: 1,
+	models.MoveBomb:  1,
+	models.MoveWall:  2,
+}
+
+// A powerUp plays a special piece into a column for player and returns the
+// row it landed on. It leaves the board untouched when it cannot be played.
+// Adding a power-up only takes a move type and an entry here; the cells it
+// changes are worked out by comparing the board before and after.
+type powerUp func(b *Board, col, player int) (int, bool)
+
+var powerUps = map[string]powerUp{
+	models.MoveAnvil: dropAnvil,
+	models.MoveBomb:  dropBomb,
+	models.MoveWall:  dropWall,
+}
+
+// IsPowerUp reports whether a move type is a power-up.
+func IsPowerUp(kind string) bool {
+	_, ok := powerUps[kind]
+	return ok
+}
+
+// UsePowerUp plays one of the player's power-ups into a column.
+func (g *GameInstance) UsePowerUp(kind string, col, playerNum int) error {
+	return g.do(command{kind: cmdPowerUp, powerUp: kind, column: col, playerNum: playerNum}).err
+}
+
+func (g *GameInstance) applyPowerUp(kind string, col, playerNum int) error {
+	playerNum, seat, err := g.checkTurn(playerNum)
+	if err != nil {
+		return err
+	}
+	if g.state.Settings.Ruleset != models.RulesetArcade {
+		return ErrPowerUpsDisabled
+	}
+	if !IsPowerUp(kind) {
+		return ErrUnknownPowerUp.With(map[string]interface{}{"powerup": kind})
+	}
+
+	inventory := g.state.Inventory[playerNum-1]
+	if inventory[kind] <= 0 {
+		return ErrNoPowerUpLeft.With(map[string]interface{}{"powerup": kind})
+	}
+
+	before := g.board.Clone()
+	row, winner, ok := resolvePowerUp(g.board, kind, col, playerNum)
+	if !ok {
+		return g.columnError(col)
+	}
+	inventory[kind]--
+
+	g.completeMove(models.Move{
+		GameID:  g.ID,
+		Column:  col,
+		Row:     row,
+		Player:  playerNum,
+		Seat:    seat,
+		Type:    kind,
+		Changes: diffBoards(before, g.board),
+	}, winner)
+	return nil
+}
+
+// resolvePowerUp plays a power-up and returns where it landed and who, if
+// anyone, now has four in a row. A power-up can complete a line for either
+// player; if both have one, the player who used it wins.
+func resolvePowerUp(board *Board, kind string, col, player int) (int, int, bool) {
+	resolve, ok := powerUps[kind]
+	if !ok {
+		return 0, 0, false
+	}
+
+	row, ok := resolve(board, col, player)
+	if !ok {
+		return 0, 0, false
+	}
+
+	switch {
+	case board.HasFour(player):
+		return row, player, true
+	case board.HasFour(3 - player):
+		return row, 3 - player, true
+	}
+	return row, 0, true
+}
+
+func newInventories() []models.Inventory {
+	inventories := make([]models.Inventory, 2)
+	for i := range inventories {
+		inventories[i] = models.Inventory{}
+		for kind, count := range DefaultInventory {
+			inventories[i][kind] = count
+		}
+	}
+	return inventories
+}
+
+// dropAnvil smashes everything in the column and comes to rest on the
+// bottom row as the player's piece. It can be played into a full column.
+func dropAnvil(b *Board, col, player int) (int, bool) {
+	if col < 0 || col >= b.Cols() {
+		return 0, false
+	}
+
+	for row := 0; row < b.Rows(); row++ {
+		b.grid[row][col] = 0
+	}
+	bottom := b.Rows() - 1
+	b.grid[bottom][col] = player
+	return bottom, true
+}
+
+// dropBomb lands like a piece and then removes itself and every piece
+// around it. Pieces above the blast fall into the gap.
+func dropBomb(b *Board, col, player int) (int, bool) {
+	row, ok := b.MakeMove(col, player)
+	if !ok {
+		return 0, false
+	}
+
+	for r := row - 1; r <= row+1; r++ {
+		for c := col - 1; c <= col+1; c++ {
+			if r >= 0 && r < b.Rows() && c >= 0 && c < b.Cols() {
+				b.grid[r][c] = 0
+			}
+		}
+	}
+	for c := col - 1; c <= col+1; c++ {
+		if c >= 0 && c < b.Cols() {
+			b.settle(c)
+		}
+	}
+	return row, true
+}
+
+// dropWall fills the next cell of the column with a neutral block.
+func dropWall(b *Board, col, player int) (int, bool) {
+	return b.MakeMove(col, Wall)
+}
+
+// settle lets the pieces of a column fall to the bottom, keeping their
+// order.
+func (b *Board) settle(col int) {
+	bottom := b.Rows() - 1
+	for row := b.Rows() - 1; row >= 0; row-- {
+		if cell := b.grid[row][col]; cell != 0 {
+			b.grid[row][col] = 0
+			b.grid[bottom][col] = cell
+			bottom--
+		}
+	}
+}
+
+// diffBoards lists the cells that differ between two boards of the same
+// size, with their values in after.
+func diffBoards(before, after *Board) []models.CellChange {
+	var changes []models.CellChange
+	for row := 0; row < after.Rows(); row++ {
+		for col := 0; col < after.Cols(); col++ {
+			if before.grid[row][col] != after.grid[row][col] {
+				changes = append(changes, models.CellChange{Row: row, Column: col, Value: after.grid[row][col]})
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,145 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func TestResolvePowerUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		moves  string
+		kind   string
+		col    int
+		player int
+		// want is the board afterwards as a board string, or "" if the
+		// power-up cannot be played.
+		want       string
+		wantRow    int
+		wantWinner int
+	}{
+		{
+			name: "anvil clears its column", moves: "444", kind: models.MoveAnvil, col: 3, player: 2,
+			want: "......./......./......./......./......./...2...", wantRow: 5,
+		},
+		{
+			name: "anvil into a full column", moves: "444444", kind: models.MoveAnvil, col: 3, player: 1,
+			want: "......./......./......./......./......./...1...", wantRow: 5,
+		},
+		{
+			name: "anvil completes four", moves: "112233", kind: models.MoveAnvil, col: 3, player: 1,
+			want: "......./......./......./......./222..../1111...", wantRow: 5, wantWinner: 1,
+		},
+		{
+			name: "anvil outside the board", moves: "", kind: models.MoveAnvil, col: 7, player: 1,
+		},
+		{
+			name: "bomb removes its neighbours", moves: "44", kind: models.MoveBomb, col: 3, player: 1,
+			want: "......./......./......./......./......./...1...", wantRow: 3,
+		},
+		{
+			name: "pieces above the blast fall", moves: "33333", kind: models.MoveBomb, col: 3, player: 2,
+			want: "......./......./......./..1..../..2..../..1....", wantRow: 5,
+		},
+		{
+			name: "bomb into a full column", moves: "444444", kind: models.MoveBomb, col: 3, player: 1,
+		},
+		{
+			name: "wall", moves: "4", kind: models.MoveWall, col: 3, player: 2,
+			want: "......./......./......./......./...#.../...1...", wantRow: 4,
+		},
+		{
+			name: "wall into a full column", moves: "444444", kind: models.MoveWall, col: 3, player: 1,
+		},
+		{
+			name: "unknown power-up", moves: "", kind: "rocket", col: 3, player: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := NewBoard()
+			for _, move := range mustMoves(t, tt.moves) {
+				board.MakeMove(move.Column, move.Player)
+			}
+			before := board.Clone()
+
+			row, winner, ok := resolvePowerUp(board, tt.kind, tt.col, tt.player)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("resolvePowerUp played at row %d, want it refused", row)
+				}
+				if changes := diffBoards(before, board); len(changes) != 0 {
+					t.Errorf("a refused power-up changed %+v", changes)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("resolvePowerUp refused the power-up")
+			}
+			if got := EncodeBoard(board.GetGrid()); got != tt.want {
+				t.Errorf("board = %s, want %s", got, tt.want)
+			}
+			if row != tt.wantRow || winner != tt.wantWinner {
+				t.Errorf("row %d, winner %d; want %d, %d", row, winner, tt.wantRow, tt.wantWinner)
+			}
+		})
+	}
+}
+
+func TestUsePowerUp(t *testing.T) {
+	arcade := models.GameSettings{Ruleset: models.RulesetArcade, Mode: models.ModeCasual}
+	tests := []struct {
+		name     string
+		settings models.GameSettings
+		kind     string
+		// again plays the same power-up on the player's next turn.
+		again   bool
+		wantErr error
+	}{
+		{"anvil", arcade, models.MoveAnvil, false, nil},
+		{"walls twice", arcade, models.MoveWall, true, nil},
+		{"second anvil", arcade, models.MoveAnvil, true, ErrNoPowerUpLeft},
+		{"standard rules", models.GameSettings{}, models.MoveBomb, false, ErrPowerUpsDisabled},
+		{"unknown power-up", arcade, "rocket", false, ErrUnknownPowerUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestTwoPlayerGame(t, tt.settings, "")
+
+			err := g.UsePowerUp(tt.kind, 3, 1)
+			if err == nil && tt.again {
+				if err := g.MakeMove(0, 2); err != nil {
+					t.Fatalf("MakeMove: %v", err)
+				}
+				err = g.UsePowerUp(tt.kind, 3, 1)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("UsePowerUp error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UsePowerUp: %v", err)
+			}
+
+			state := g.Snapshot()
+			uses := 1
+			if tt.again {
+				uses = 2
+			}
+			if left := state.Inventory[0][tt.kind]; left != DefaultInventory[tt.kind]-uses {
+				t.Errorf("%d %s left, want %d", left, tt.kind, DefaultInventory[tt.kind]-uses)
+			}
+			last := state.Moves[len(state.Moves)-1]
+			if last.Type != tt.kind || len(last.Changes) == 0 {
+				t.Errorf("last move = %+v, want a %s with its changes", last, tt.kind)
+			}
+			if state.CurrentTurn != 2 {
+				t.Errorf("current turn = %d, want 2", state.CurrentTurn)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Game records use a PGN-like text format: a block of [Name "Value"] header
+// tags, a blank line, then the numbered move list in 1-indexed columns with
+// optional {comments}, ending with the result.
+//
+//	[Event "4 in a Row"]
+//	[Date "2025.11.14"]
+//	[Player1 "alice"]
+//	[Player2 "bob"]
+//	[Result "1-0"]
+//	[Ruleset "standard"]
+//	[TimeControl "120/move"]
+//	[Termination "win"]
+//
+//	1. 4 4 2. 5 {threatens both sides} 3 3. 6 1-0
+//
+// The word "undo" records a takeback of the last move still on the board,
+// "swap" records the second player taking over the opening move, and a
+// power-up is written as its name and column, such as "bomb:4". In 3D games
+// the columns are the pegs, numbered 1 to 16 across the grid.
+
+// Result tokens used in records.
+const (
+	RecordPlayer1Wins = "1-0"
+	RecordPlayer2Wins = "0-1"
+	RecordDraw        = "1/2-1/2"
+	RecordUnfinished  = "*"
+)
+
+const (
+	recordUndo       = "undo"
+	recordSwap       = "swap"
+	recordDateLayout = "2006.01.02"
+	defaultRuleset   = "standard"
+	recordLineWidth  = 80
+)
+
+// Tag is a single header tag of a record.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Record is a parsed game record.
+type Record struct {
+	Tags   []Tag
+	Moves  []models.Move
+	Result string
+
+	// board and turn are the position after Moves, kept up to date as the
+	// moves are parsed. standing holds the indices in Moves of the pieces an
+	// undo may take back, latest last.
+	board    *Board
+	turn     int
+	standing []int
+	decided  bool
+}
+
+// Tag returns the value of the named tag, or "" if it is absent.
+func (r *Record) Tag(name string) string {
+	for _, tag := range r.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// ExportRecord writes a stored game in record format.
+func ExportRecord(record *models.GameRecord) string {
+	result := recordResult(record)
+
+	ruleset := record.Ruleset
+	if ruleset == "" {
+		ruleset = defaultRuleset
+	}
+	timeControl := record.TimeControl
+	if timeControl == "" {
+		timeControl = fmt.Sprintf("%d/move", int(DefaultTurnTimeout.Seconds()))
+	}
+	termination := record.EndReason
+	if termination == "" {
+		termination = "unknown"
+	}
+
+	tags := []Tag{
+		{"Event", "4 in a Row"},
+		{"Date", record.CreatedAt.Format(recordDateLayout)},
+		{"GameID", record.ID},
+		{"Player1", record.Player1Username},
+		{"Player2", record.Player2Username},
+		{"Result", result},
+		{"Ruleset", ruleset},
+		{"TimeControl", timeControl},
+		{"Termination", termination},
+	}
+	if record.Opening != "" {
+		tags = append(tags, Tag{"Opening", record.Opening})
+	}
+	if record.StartFEN != "" {
+		tags = append(tags, Tag{"SetUp", "1"}, Tag{"FEN", record.StartFEN})
+	}
+
+	var sb strings.Builder
+	for _, tag := range tags {
+		fmt.Fprintf(&sb, "[%s %s]\n", tag.Name, strconv.Quote(tag.Value))
+	}
+	sb.WriteByte('\n')
+
+	var tokens []string
+	ply := 0
+	for _, move := range record.Moves {
+		if move.Type == models.MoveTakeback {
+			tokens = append(tokens, recordUndo)
+			ply--
+			continue
+		}
+		if move.Type == models.MoveSwap {
+			tokens = append(tokens, recordSwap)
+			continue
+		}
+		if ply%2 == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d.", ply/2+1))
+		}
+		column := strconv.Itoa(move.Column + 1)
+		if IsPowerUp(move.Type) {
+			column = move.Type + ":" + column
+		}
+		tokens = append(tokens, column)
+		ply++
+		if move.Comment != "" {
+			tokens = append(tokens, "{"+strings.ReplaceAll(move.Comment, "}", ")")+"}")
+		}
+	}
+	tokens = append(tokens, result)
+
+	width := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if width+1+len(token) > recordLineWidth {
+				sb.WriteByte('\n')
+				width = 0
+			} else {
+				sb.WriteByte(' ')
+				width++
+			}
+		}
+		sb.WriteString(token)
+		width += len(token)
+	}
+	sb.WriteByte('\n')
+
+	return sb.String()
+}
+
+// ParseRecords reads one or more records from text.
+func ParseRecords(text string) ([]*Record, error) {
+	var records []*Record
+	var current *Record
+
+	p := &recordParser{text: text}
+	for {
+		token, kind, err := p.next()
+		if err != nil {
+			return records, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		if kind == tokenEOF {
+			break
+		}
+
+		// A tag after the move list starts the next record
+		if current == nil || (kind == tokenTag && (len(current.Moves) > 0 || current.Result != "")) {
+			current = &Record{}
+			records = append(records, current)
+		}
+
+		switch kind {
+		case tokenTag:
+			tag, err := parseTag(token)
+			if err != nil {
+				return records, fmt.Errorf("record %d: %w", len(records), err)
+			}
+			current.Tags = append(current.Tags, tag)
+		case tokenComment:
+			if len(current.Moves) == 0 {
+				continue
+			}
+			last := &current.Moves[len(current.Moves)-1]
+			last.Comment = strings.TrimSpace(last.Comment + " " + strings.TrimSpace(token))
+		case tokenWord:
+			if err := current.addWord(token); err != nil {
+				return records, fmt.Errorf("record %d: %w", len(records), err)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// GameRecord checks the record's moves and result and converts it to a
+// stored game. Imported players are given synthetic IDs derived from their
+// usernames.
+func (r *Record) GameRecord() (*models.GameRecord, error) {
+	player1, player2 := r.Tag("Player1"), r.Tag("Player2")
+	if player1 == "" || player2 == "" {
+		return nil, fmt.Errorf("Player1 and Player2 tags are required")
+	}
+
+	replay, err := ReplayGame(r.Tag("Ruleset"), r.Tag("FEN"), 2, r.Moves)
+	if err != nil {
+		return nil, err
+	}
+
+	result := r.Result
+	if result == "" {
+		result = r.Tag("Result")
+	}
+
+	record := &models.GameRecord{
+		Player1ID:       "imported-" + player1,
+		Player1Username: player1,
+		Player2ID:       "imported-" + player2,
+		Player2Username: player2,
+		Status:          models.StatusFinished,
+		EndReason:       r.Tag("Termination"),
+		Moves:           r.Moves,
+		StartFEN:        r.Tag("FEN"),
+		Ruleset:         r.Tag("Ruleset"),
+		Opening:         r.Tag("Opening"),
+		TimeControl:     r.Tag("TimeControl"),
+		Source:          models.SourceImport,
+		CreatedAt:       time.Now(),
+	}
+	if date, err := time.Parse(recordDateLayout, r.Tag("Date")); err == nil {
+		record.CreatedAt = date
+	}
+	finished := record.CreatedAt
+	record.FinishedAt = &finished
+
+	switch result {
+	case RecordPlayer1Wins:
+		record.WinnerID, record.WinnerUsername = record.Player1ID, player1
+	case RecordPlayer2Wins:
+		record.WinnerID, record.WinnerUsername = record.Player2ID, player2
+	case RecordDraw:
+	default:
+		return nil, fmt.Errorf("result %q is not a finished game", result)
+	}
+
+	// Without a Termination tag, infer how the game ended: on the board if
+	// the moves decide it, otherwise by resignation or agreed draw
+	if record.EndReason == "" {
+		switch {
+		case replay.Result != "":
+			record.EndReason = replay.Result
+		case record.WinnerID == "":
+			record.EndReason = "agreement"
+		default:
+			record.EndReason = ResultResign
+		}
+	}
+	if _, err := VerifyRecord(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (r *Record) addWord(word string) error {
+	switch word {
+	case RecordPlayer1Wins, RecordPlayer2Wins, RecordDraw, RecordUnfinished:
+		r.Result = word
+		return nil
+	}
+
+	// Move numbers such as "12." carry no information
+	if strings.HasSuffix(word, ".") {
+		if _, err := strconv.Atoi(strings.TrimRight(word, ".")); err == nil {
+			return nil
+		}
+	}
+
+	if r.Result != "" {
+		return fmt.Errorf("move %q after the result", word)
+	}
+
+	if r.board == nil {
+		board, turn, err := loadStart(r.Tag("Ruleset"), r.Tag("FEN"))
+		if err != nil {
+			return err
+		}
+		r.board, r.turn = board, turn
+	}
+	if r.decided {
+		return fmt.Errorf("move %d: game is already over", len(r.Moves)+1)
+	}
+	board, player := r.board, r.turn
+
+	switch word {
+	case recordUndo:
+		if len(r.standing) == 0 {
+			return fmt.Errorf("move %d: nothing to undo", len(r.Moves)+1)
+		}
+		last := r.Moves[r.standing[len(r.standing)-1]]
+		r.standing = r.standing[:len(r.standing)-1]
+		board.UnmakeMove(last.Column)
+		r.turn = last.Player
+
+		last.Type = models.MoveTakeback
+		last.Comment = ""
+		r.Moves = append(r.Moves, last)
+		return nil
+	case recordSwap:
+		// The opening move changes hands and can no longer be undone
+		r.standing = nil
+		r.Moves = append(r.Moves, models.Move{Player: player, Type: models.MoveSwap})
+		return nil
+	}
+
+	kind, column, isPowerUp := strings.Cut(word, ":")
+	if !isPowerUp {
+		kind, column = "", word
+	} else if !IsPowerUp(kind) {
+		return fmt.Errorf("move %d: unknown power-up %q", len(r.Moves)+1, kind)
+	}
+
+	col, err := strconv.Atoi(column)
+	if err != nil || col < 1 || col > board.Cols() {
+		return fmt.Errorf("move %d: %q is not a column between 1 and %d", len(r.Moves)+1, word, board.Cols())
+	}
+
+	before := board.Clone()
+	var row, winner int
+	var ok bool
+	if isPowerUp {
+		row, winner, ok = resolvePowerUp(board, kind, col-1, player)
+	} else {
+		row, ok = board.MakeMove(col-1, player)
+		if ok && board.CheckWin(row, col-1, player) {
+			winner = player
+		}
+	}
+	if !ok {
+		return fmt.Errorf("move %d: column %d is full", len(r.Moves)+1, col)
+	}
+
+	move := models.Move{Column: col - 1, Row: row, Player: player, Type: kind}
+	if isPowerUp {
+		move.Changes = diffBoards(before, board)
+	}
+	r.standing = append(r.standing, len(r.Moves))
+	r.Moves = append(r.Moves, move)
+	r.decided = winner != 0 || board.IsFull()
+	r.turn = 3 - player
+	return nil
+}
+
+func recordResult(record *models.GameRecord) string {
+	switch {
+	case record.Status != models.StatusFinished:
+		return RecordUnfinished
+	case record.WinnerID == "":
+		return RecordDraw
+	case record.WinnerID == record.Player1ID:
+		return RecordPlayer1Wins
+	default:
+		return RecordPlayer2Wins
+	}
+}
+
+func parseTag(token string) (Tag, error) {
+	name, value, ok := strings.Cut(token, " ")
+	if !ok || name == "" {
+		return Tag{}, fmt.Errorf("malformed tag [%s]", token)
+	}
+
+	unquoted, err := strconv.Unquote(strings.TrimSpace(value))
+	if err != nil {
+		return Tag{}, fmt.Errorf("malformed value in tag [%s]", token)
+	}
+
+	return Tag{Name: name, Value: unquoted}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenTag
+	tokenComment
+	tokenWord
+)
+
+type recordParser struct {
+	text string
+	pos  int
+}
+
+// next returns the next tag body, comment body or whitespace-separated word.
+func (p *recordParser) next() (string, tokenKind, error) {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.text) {
+		return "", tokenEOF, nil
+	}
+
+	switch p.text[p.pos] {
+	case '[':
+		end := strings.IndexByte(p.text[p.pos:], ']')
+		if end < 0 {
+			return "", tokenEOF, fmt.Errorf("unterminated tag")
+		}
+		token := p.text[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		return token, tokenTag, nil
+	case '{':
+		end := strings.IndexByte(p.text[p.pos:], '}')
+		if end < 0 {
+			return "", tokenEOF, fmt.Errorf("unterminated comment")
+		}
+		token := p.text[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		return token, tokenComment, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.text) && !unicode.IsSpace(rune(p.text[p.pos])) && p.text[p.pos] != '{' && p.text[p.pos] != '[' {
+		p.pos++
+	}
+	return p.text[start:p.pos], tokenWord, nil
+}
@@ -0,0 +1,147 @@
+package game
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		record *models.GameRecord
+	}{
+		{
+			name: "win with comments",
+			record: &models.GameRecord{
+				Moves:     withComment(mustMoves(t, "1212121"), 2, "threatens the column"),
+				EndReason: ResultWin,
+				WinnerID:  "imported-alice",
+			},
+		},
+		{
+			name: "resigned from a custom start",
+			record: &models.GameRecord{
+				StartFEN:  "7/7/7/7/7/3x3 o",
+				Moves:     []models.Move{{Column: 3, Row: 4, Player: 2}, {Column: 0, Row: 5, Player: 1}},
+				EndReason: ResultResign,
+				WinnerID:  "imported-bob",
+			},
+		},
+		{
+			name: "takebacks and a swap",
+			record: &models.GameRecord{
+				Moves: []models.Move{
+					{Column: 3, Row: 5, Player: 1},
+					{Player: 2, Type: models.MoveSwap},
+					{Column: 4, Row: 5, Player: 2},
+					{Column: 4, Row: 5, Player: 2, Type: models.MoveTakeback},
+					{Column: 2, Row: 5, Player: 2},
+				},
+				EndReason: "agreement",
+			},
+		},
+		{
+			name: "power-ups",
+			record: &models.GameRecord{
+				Ruleset: models.RulesetArcade,
+				Moves: []models.Move{
+					{Column: 3, Row: 5, Player: 1},
+					{Column: 3, Row: 4, Player: 2, Type: models.MoveWall, Changes: []models.CellChange{{Row: 4, Column: 3, Value: Wall}}},
+				},
+				EndReason: ResultResign,
+				WinnerID:  "imported-alice",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.Player1ID, tt.record.Player1Username = "imported-alice", "alice"
+			tt.record.Player2ID, tt.record.Player2Username = "imported-bob", "bob"
+			tt.record.Status = models.StatusFinished
+			tt.record.CreatedAt = time.Date(2025, 11, 14, 0, 0, 0, 0, time.UTC)
+
+			text := ExportRecord(tt.record)
+			records, err := ParseRecords(text)
+			if err != nil {
+				t.Fatalf("ParseRecords: %v\n%s", err, text)
+			}
+			if len(records) != 1 {
+				t.Fatalf("parsed %d records, want 1", len(records))
+			}
+			got, err := records[0].GameRecord()
+			if err != nil {
+				t.Fatalf("GameRecord: %v\n%s", err, text)
+			}
+
+			if !reflect.DeepEqual(got.Moves, tt.record.Moves) {
+				t.Errorf("moves = %+v, want %+v", got.Moves, tt.record.Moves)
+			}
+			if got.WinnerID != tt.record.WinnerID || got.EndReason != tt.record.EndReason || got.StartFEN != tt.record.StartFEN {
+				t.Errorf("got winner %q, end %q, start %q; want %q, %q, %q",
+					got.WinnerID, got.EndReason, got.StartFEN, tt.record.WinnerID, tt.record.EndReason, tt.record.StartFEN)
+			}
+			if again := ExportRecord(got); again != text {
+				t.Errorf("export after import differs:\n%s\nwant:\n%s", again, text)
+			}
+		})
+	}
+}
+
+func withComment(moves []models.Move, i int, comment string) []models.Move {
+	moves[i].Comment = comment
+	return moves
+}
+
+func TestParseRecordsRejects(t *testing.T) {
+	const header = "[Player1 \"alice\"]\n[Player2 \"bob\"]\n\n"
+	tests := []struct {
+		name    string
+		fen     string
+		moves   string
+		wantErr string
+	}{
+		{"floating piece in the start", "7/7/7/7/3x3/7 o", "1. 4", "floating"},
+		{"start already decided", "7/7/7/o6/o6/xxxxoo1 o", "1. 7", "already decided"},
+		{"unterminated comment", "", "1. 4 {oops", "unterminated comment"},
+		{"column out of range", "", "1. 8", "not a column"},
+		{"full column", "", "1. 4 4 2. 4 4 3. 4 4 4. 4", "column 4 is full"},
+		{"undo with nothing played", "", "undo", "nothing to undo"},
+		{"undo past a swap", "", "1. 4 swap undo", "nothing to undo"},
+		{"move after a win", "", "1. 1 2 2. 1 2 3. 1 2 4. 1 2", "already over"},
+		{"move after the result", "", "1. 4 4 1-0 5", "after the result"},
+		{"unknown power-up", "", "1. rocket:4", "unknown power-up"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := header
+			if tt.fen != "" {
+				text = "[SetUp \"1\"]\n[FEN \"" + tt.fen + "\"]\n" + text
+			}
+			_, err := ParseRecords(text + tt.moves + "\n")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseRecords error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Takebacks are applied to the position as they are read, however many
+// there are.
+func TestParseRecordsUndo(t *testing.T) {
+	text := "[Player1 \"alice\"]\n[Player2 \"bob\"]\n\n" + strings.Repeat("4 undo ", 5000) + "4 5 undo 3 *\n"
+	records, err := ParseRecords(text)
+	if err != nil {
+		t.Fatalf("ParseRecords: %v", err)
+	}
+	moves := records[0].Moves
+	if got := FormatMoveString(moves); got != "43" {
+		t.Errorf("standing moves = %q, want \"43\"", got)
+	}
+	if last := moves[len(moves)-1]; last.Player != 2 || last.Row != Rows-1 {
+		t.Errorf("last move = %+v, want player 2 on the bottom row", last)
+	}
+}
@@ -0,0 +1,217 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Position is the board after a given ply of a replayed game. Ply 0 is the
+// starting position and carries no move.
+type Position struct {
+	Ply   int          `json:"ply"`
+	Move  *models.Move `json:"move,omitempty"`
+	Board [][]int      `json:"board"`
+}
+
+// ReplayResult is a game rebuilt from its move log.
+type ReplayResult struct {
+	Positions []Position `json:"positions"`
+	// Result is ResultWin or ResultDraw when the moves end the game on the
+	// board, and empty otherwise (unfinished, resigned or timed out).
+	Result string `json:"result,omitempty"`
+	// Winner is the seat that connected four, or 0.
+	Winner int `json:"winner"`
+
+	board *Board
+	turn  int
+}
+
+// ReplayError reports the first move that could not have been played.
+type ReplayError struct {
+	Ply    int
+	Reason string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("move %d: %s", e.Ply, e.Reason)
+}
+
+// Replay rebuilds a game position by position from its move log, checking
+// that every move was legal at the time it was recorded. startFEN is the
+// game's stored starting position, empty for the standard start.
+func Replay(startFEN string, moves []models.Move) (*ReplayResult, error) {
+	return ReplayGame("", startFEN, 2, moves)
+}
+
+// ReplayGame is Replay for a game with the given ruleset and number of
+// players.
+func ReplayGame(ruleset, startFEN string, seats int, moves []models.Move) (*ReplayResult, error) {
+	board, turn, err := loadStart(ruleset, startFEN)
+	if err != nil {
+		return nil, err
+	}
+	if seats > 2 {
+		board = NewBoardSize(BoardSize(seats))
+	}
+	out := make([]bool, seats+1)
+	isOut := func(seat int) bool { return out[seat] }
+
+	result := &ReplayResult{
+		Positions: []Position{{Ply: 0, Board: board.Clone().GetGrid()}},
+		board:     board,
+	}
+
+	for i, move := range moves {
+		ply := i + 1
+
+		if result.Result != "" {
+			return result, &ReplayError{Ply: ply, Reason: "move played after the game ended"}
+		}
+		if move.Type == models.MoveTakeback {
+			if err := replayTakeback(board, moves[:i], move); err != nil {
+				return result, &ReplayError{Ply: ply, Reason: err.Error()}
+			}
+			m := move
+			result.Positions = append(result.Positions, Position{
+				Ply:   ply,
+				Move:  &m,
+				Board: board.Clone().GetGrid(),
+			})
+			turn = move.Player
+			continue
+		}
+
+		if move.Type == models.MoveEliminated {
+			if seats <= 2 || move.Player < 1 || move.Player > seats || out[move.Player] {
+				return result, &ReplayError{Ply: ply, Reason: fmt.Sprintf("player %d cannot be knocked out", move.Player)}
+			}
+			out[move.Player] = true
+			if move.Player == turn {
+				turn = nextActiveSeat(turn, seats, isOut)
+			}
+			m := move
+			result.Positions = append(result.Positions, Position{
+				Ply:   ply,
+				Move:  &m,
+				Board: board.Clone().GetGrid(),
+			})
+			continue
+		}
+
+		// A swap exchanges the seats but leaves the board and the turn as
+		// they are: the new seat 2 replies to its own opening move
+		if move.Type == models.MoveSwap {
+			if i != 1 || moves[0].Type != "" || move.Player != turn {
+				return result, &ReplayError{Ply: ply, Reason: "swap is only allowed as the reply to the first move"}
+			}
+			m := move
+			result.Positions = append(result.Positions, Position{
+				Ply:   ply,
+				Move:  &m,
+				Board: board.Clone().GetGrid(),
+			})
+			continue
+		}
+
+		if move.Player != turn {
+			return result, &ReplayError{Ply: ply, Reason: fmt.Sprintf("player %d moved out of turn", move.Player)}
+		}
+
+		var row, winner int
+		var ok bool
+		if IsPowerUp(move.Type) {
+			row, winner, ok = resolvePowerUp(board, move.Type, move.Column, move.Player)
+		} else if move.Type == "" {
+			row, ok = board.MakeMove(move.Column, move.Player)
+			if ok && board.CheckWin(row, move.Column, move.Player) {
+				winner = move.Player
+			}
+		}
+		if !ok {
+			return result, &ReplayError{Ply: ply, Reason: fmt.Sprintf("column %d is not playable", move.Column)}
+		}
+		if row != move.Row {
+			return result, &ReplayError{Ply: ply, Reason: fmt.Sprintf("recorded row %d, piece lands on row %d", move.Row, row)}
+		}
+
+		m := move
+		result.Positions = append(result.Positions, Position{
+			Ply:   ply,
+			Move:  &m,
+			Board: board.Clone().GetGrid(),
+		})
+
+		if winner != 0 {
+			result.Result = ResultWin
+			result.Winner = winner
+		} else if board.IsFull() {
+			result.Result = ResultDraw
+		}
+
+		if seats > 2 {
+			turn = nextActiveSeat(turn, seats, isOut)
+		} else {
+			turn = 3 - turn
+		}
+	}
+
+	result.turn = turn
+	return result, nil
+}
+
+// replayTakeback checks that a takeback entry removes the most recent piece
+// still on the board and removes it.
+func replayTakeback(board *Board, earlier []models.Move, entry models.Move) error {
+	last, ok := lastStandingMove(afterSwap(earlier))
+	if !ok {
+		return fmt.Errorf("takeback with no move on the board")
+	}
+	if last.Column != entry.Column || last.Row != entry.Row || last.Player != entry.Player {
+		return fmt.Errorf("takeback of column %d does not match the last move", entry.Column)
+	}
+
+	board.UnmakeMove(entry.Column)
+	return nil
+}
+
+// VerifyRecord replays a stored game and checks that the recorded outcome
+// matches what the moves produce.
+func VerifyRecord(record *models.GameRecord) (*ReplayResult, error) {
+	seats := 2
+	if len(record.Seats) > 2 && !record.Teams {
+		seats = len(record.Seats)
+	}
+	result, err := ReplayGame(record.Ruleset, record.StartFEN, seats, record.Moves)
+	if err != nil {
+		return result, err
+	}
+
+	switch record.EndReason {
+	case ResultWin, ResultDraw:
+		if result.Result != record.EndReason {
+			return result, fmt.Errorf("recorded %s, moves produce %q", record.EndReason, result.Result)
+		}
+	case ResultResign, ResultTimeout, ResultAbandoned:
+		if result.Result != "" {
+			return result, fmt.Errorf("recorded %s, but the moves already end the game", record.EndReason)
+		}
+		return result, nil
+	}
+
+	winnerID := ""
+	switch {
+	case result.Winner == 0:
+	case seats > 2:
+		winnerID = record.Seats[result.Winner-1].ID
+	case result.Winner == 1:
+		winnerID = record.Player1ID
+	case result.Winner == 2:
+		winnerID = record.Player2ID
+	}
+	if winnerID != record.WinnerID {
+		return result, fmt.Errorf("recorded winner %q, moves produce %q", record.WinnerID, winnerID)
+	}
+
+	return result, nil
+}
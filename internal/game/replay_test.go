@@ -0,0 +1,167 @@
+package game
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func mustMoves(t *testing.T, s string) []models.Move {
+	t.Helper()
+	moves, err := ParseMoveString(s)
+	if err != nil {
+		t.Fatalf("ParseMoveString(%q): %v", s, err)
+	}
+	return moves
+}
+
+func TestReplayRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		startFEN string
+		moves    func(t *testing.T) []models.Move
+		wantErr  string
+		// wantPly is the ply of the bad move, or 0 if the start itself is
+		// rejected and nothing is replayed.
+		wantPly int
+	}{
+		{
+			name:     "start without side to move",
+			startFEN: "7/7/7/7/7/7",
+			moves:    func(t *testing.T) []models.Move { return nil },
+			wantErr:  "missing side to move",
+		},
+		{
+			name:     "start with bad side to move",
+			startFEN: "7/7/7/7/7/7 z",
+			moves:    func(t *testing.T) []models.Move { return nil },
+			wantErr:  "side to move",
+		},
+		{
+			name: "out of turn",
+			moves: func(t *testing.T) []models.Move {
+				moves := mustMoves(t, "44")
+				moves[1].Player = 1
+				return moves
+			},
+			wantErr: "moved out of turn",
+			wantPly: 2,
+		},
+		{
+			name: "wrong row",
+			moves: func(t *testing.T) []models.Move {
+				moves := mustMoves(t, "445")
+				moves[2].Row = 4
+				return moves
+			},
+			wantErr: "lands on row",
+			wantPly: 3,
+		},
+		{
+			name: "full column",
+			moves: func(t *testing.T) []models.Move {
+				moves := mustMoves(t, "444444")
+				return append(moves, models.Move{Column: 3, Row: -1, Player: 1})
+			},
+			wantErr: "not playable",
+			wantPly: 7,
+		},
+		{
+			name: "move after a win",
+			moves: func(t *testing.T) []models.Move {
+				moves := mustMoves(t, "1212121")
+				return append(moves, models.Move{Column: 3, Row: Rows - 1, Player: 2})
+			},
+			wantErr: "after the game ended",
+			wantPly: 8,
+		},
+		{
+			name: "takeback of the wrong move",
+			moves: func(t *testing.T) []models.Move {
+				moves := mustMoves(t, "45")
+				return append(moves, models.Move{Column: 3, Row: Rows - 1, Player: 1, Type: models.MoveTakeback})
+			},
+			wantErr: "does not match the last move",
+			wantPly: 3,
+		},
+		{
+			name: "late swap",
+			moves: func(t *testing.T) []models.Move {
+				moves := mustMoves(t, "45")
+				return append(moves, models.Move{Player: 1, Type: models.MoveSwap})
+			},
+			wantErr: "swap is only allowed",
+			wantPly: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Replay(tt.startFEN, tt.moves(t))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Replay error = %v, want one containing %q", err, tt.wantErr)
+			}
+			if tt.wantPly == 0 {
+				if result != nil {
+					t.Errorf("Replay returned %d positions for a bad start, want none", len(result.Positions))
+				}
+				return
+			}
+			replayErr, ok := err.(*ReplayError)
+			if !ok || replayErr.Ply != tt.wantPly {
+				t.Errorf("Replay error = %#v, want a ReplayError at ply %d", err, tt.wantPly)
+			}
+			// Everything up to the bad move is still replayed
+			if result == nil || len(result.Positions) != tt.wantPly {
+				t.Errorf("Replay returned %+v, want %d positions", result, tt.wantPly)
+			}
+		})
+	}
+}
+
+func TestVerifyRecord(t *testing.T) {
+	record := func(moves, endReason, winnerID string) *models.GameRecord {
+		return &models.GameRecord{
+			Player1ID: "p1",
+			Player2ID: "p2",
+			Moves:     mustMoves(t, moves),
+			EndReason: endReason,
+			WinnerID:  winnerID,
+		}
+	}
+	tests := []struct {
+		name    string
+		record  *models.GameRecord
+		wantErr string
+	}{
+		{"win", record("1212121", ResultWin, "p1"), ""},
+		{"resigned", record("4455", ResultResign, "p2"), ""},
+		{"win recorded as a draw", record("1212121", ResultDraw, ""), "moves produce"},
+		{"wrong winner", record("1212121", ResultWin, "p2"), "recorded winner"},
+		{"win never reached", record("4455", ResultWin, "p1"), "moves produce"},
+		{"resigned after a win", record("1212121", ResultResign, "p2"), "already end the game"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := VerifyRecord(tt.record)
+			if result == nil {
+				t.Fatalf("VerifyRecord returned no replay (error %v)", err)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifyRecord: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyRecord error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	bad := record("44", ResultResign, "p1")
+	bad.StartFEN = "not a position"
+	if result, err := VerifyRecord(bad); err == nil || result != nil {
+		t.Errorf("VerifyRecord with a bad start = %+v, %v; want nil and an error", result, err)
+	}
+}
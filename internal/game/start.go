@@ -0,0 +1,167 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// StartingPosition builds the board a game with the given settings starts
+// from and the seat to move first. The returned FEN is empty for the
+// standard start and otherwise records the position for replays. Settings
+// that cannot be played fail with ErrInvalidSettings.
+func StartingPosition(settings models.GameSettings) (*Board, int, string, error) {
+	board, toMove, fen, err := startingPosition(settings)
+	if err != nil {
+		return nil, 0, "", invalidSettings(err)
+	}
+	return board, toMove, fen, nil
+}
+
+func startingPosition(settings models.GameSettings) (*Board, int, string, error) {
+	board, toMove := NewBoard(), 1
+	if settings.Ruleset == models.Ruleset3D {
+		board = NewCube()
+	}
+	custom := false
+
+	if settings.Opening != "" {
+		if strings.TrimSpace(settings.StartPosition) != "" {
+			return nil, 0, "", fmt.Errorf("a game cannot have both an opening and a start position")
+		}
+		opening, ok := OpeningByID(settings.Opening)
+		if !ok {
+			return nil, 0, "", fmt.Errorf("unknown opening %q", settings.Opening)
+		}
+		settings.StartPosition = opening.Moves
+	}
+
+	if position := strings.TrimSpace(settings.StartPosition); position != "" {
+		var err error
+		board, toMove, err = decodeStartPosition(position)
+		if err != nil {
+			return nil, 0, "", fmt.Errorf("start position: %w", err)
+		}
+		custom = true
+	}
+
+	if handicap := settings.Handicap; handicap != nil && len(handicap.Columns) > 0 {
+		if handicap.Seat != 1 && handicap.Seat != 2 {
+			return nil, 0, "", fmt.Errorf("handicap seat must be 1 or 2")
+		}
+		for _, col := range handicap.Columns {
+			if _, ok := board.MakeMove(col, handicap.Seat); !ok {
+				return nil, 0, "", fmt.Errorf("handicap column %d is not playable", col+1)
+			}
+		}
+		custom = true
+	}
+
+	if settings.FirstTurn != 0 {
+		if settings.FirstTurn != 1 && settings.FirstTurn != 2 {
+			return nil, 0, "", fmt.Errorf("first turn must be 1 or 2")
+		}
+		toMove = settings.FirstTurn
+		custom = custom || toMove != 1
+	}
+
+	switch settings.Ruleset {
+	case "", models.RulesetStandard, models.RulesetBalanced:
+	case models.RulesetSwap, models.RulesetArcade, models.Ruleset3D:
+		// The swap offer is about the opening move, which a prepared
+		// position has already made, arcade inventories assume a fresh
+		// board, and positions are only written for the classic board
+		if custom {
+			return nil, 0, "", fmt.Errorf("the %s rules need the standard start", settings.Ruleset)
+		}
+	default:
+		return nil, 0, "", fmt.Errorf("unknown ruleset %q", settings.Ruleset)
+	}
+
+	if settings.TurnSeconds != 0 && (settings.TurnSeconds < MinTurnSeconds || settings.TurnSeconds > MaxTurnSeconds) {
+		return nil, 0, "", fmt.Errorf("time per move must be between %d and %d seconds", MinTurnSeconds, MaxTurnSeconds)
+	}
+
+	switch settings.BotLevel {
+	case "", models.BotEasy, models.BotMedium, models.BotHard:
+	default:
+		return nil, 0, "", fmt.Errorf("unknown bot level %q", settings.BotLevel)
+	}
+
+	if settings.Seats != 0 && settings.Seats != 2 {
+		if settings.Seats < 2 || settings.Seats > MaxSeats {
+			return nil, 0, "", fmt.Errorf("a game needs 2 to %d players", MaxSeats)
+		}
+		if custom || (settings.Ruleset != "" && settings.Ruleset != models.RulesetStandard) {
+			return nil, 0, "", fmt.Errorf("games for more than two players use the standard rules and start")
+		}
+	}
+
+	if board.HasFour(1) || board.HasFour(2) || board.IsFull() {
+		return nil, 0, "", fmt.Errorf("start position is already decided")
+	}
+
+	if !custom {
+		return board, toMove, "", nil
+	}
+	return board, toMove, EncodeFEN(board, toMove), nil
+}
+
+// decodeStartPosition accepts a move string or a FEN-style board.
+func decodeStartPosition(position string) (*Board, int, error) {
+	if strings.Contains(position, "/") {
+		return DecodeFEN(position)
+	}
+
+	moves, err := ParseMoveString(position)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	board := NewBoard()
+	for _, move := range moves {
+		board.MakeMove(move.Column, move.Player)
+	}
+	return board, 1 + len(moves)%2, nil
+}
+
+// loadStart rebuilds the starting board of a stored or imported game. The
+// pieces must stand on each other and the position must still be open, as
+// for a start position. The piece counts are not checked against the side
+// to move: handicaps and a second-player first turn produce positions that
+// could not arise in play but are legal starts.
+func loadStart(ruleset, fen string) (*Board, int, error) {
+	if ruleset == models.Ruleset3D {
+		if fen != "" {
+			return nil, 0, fmt.Errorf("start position: 3D games start from the empty board")
+		}
+		return NewCube(), 1, nil
+	}
+	if fen == "" {
+		return NewBoard(), 1, nil
+	}
+
+	placement, side, ok := strings.Cut(strings.TrimSpace(fen), " ")
+	if !ok {
+		return nil, 0, fmt.Errorf("start position: missing side to move")
+	}
+
+	toMove, err := fenSeat(side)
+	if err != nil {
+		return nil, 0, fmt.Errorf("start position: %w", err)
+	}
+
+	board, err := decodePlacement(placement)
+	if err != nil {
+		return nil, 0, fmt.Errorf("start position: %w", err)
+	}
+	if err := checkFloating(board); err != nil {
+		return nil, 0, fmt.Errorf("start position: %w", err)
+	}
+	if board.HasFour(1) || board.HasFour(2) || board.IsFull() {
+		return nil, 0, fmt.Errorf("start position is already decided")
+	}
+
+	return board, toMove, nil
+}
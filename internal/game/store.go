@@ -0,0 +1,38 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Store persists the state of unfinished games so they survive a restart.
+type Store interface {
+	SaveActiveGame(game *models.Game) error
+	DeleteActiveGame(gameID string) error
+	LoadActiveGames() ([]*models.Game, error)
+}
+
+// Restore rebuilds a game from its persisted state. The board is replayed
+// from the move log rather than trusted as stored, and the turn clock starts
+// afresh so players have time to reconnect.
+func Restore(state *models.Game) (*GameInstance, error) {
+	replayed, err := ReplayGame(state.Settings.Ruleset, state.StartFEN, colours(state), state.Moves)
+	if err != nil {
+		return nil, fmt.Errorf("game %s: %w", state.ID, err)
+	}
+	board := replayed.board
+
+	restored := *state
+	restored.Board = board.GetGrid()
+	restored.TurnDeadline = nil
+	restored.UpdatedAt = time.Now()
+	if restored.Moves == nil {
+		restored.Moves = []models.Move{}
+	}
+
+	g := newInstance(&restored, board)
+	g.restored = true
+	return g, nil
+}
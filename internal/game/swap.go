@@ -0,0 +1,66 @@
+package game
+
+import (
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Swap lets seat 2 take over the opening move in a swap-rule game. The two
+// players exchange seats: the swapper becomes seat 1 and owns the piece
+// already on the board, and the original first player, now seat 2, moves
+// next.
+func (g *GameInstance) Swap(playerNum int) error {
+	return g.do(command{kind: cmdSwap, playerNum: playerNum}).err
+}
+
+func (g *GameInstance) applySwap(playerNum int) error {
+	if g.state.Status != models.StatusPlaying {
+		return g.notPlaying()
+	}
+	if playerNum != g.state.CurrentTurn {
+		return g.notYourTurn()
+	}
+	if !CanSwap(g.state) {
+		return ErrSwapNotAllowed
+	}
+
+	g.state.Player1, g.state.Player2 = g.state.Player2, g.state.Player1
+	g.state.Player1.Piece = 1
+	g.state.Player2.Piece = 2
+
+	entry := models.Move{
+		GameID: g.ID,
+		Player: playerNum,
+		Type:   models.MoveSwap,
+	}
+	g.state.Moves = append(g.state.Moves, entry)
+	g.state.TakebackRequest = 0
+	g.state.UpdatedAt = time.Now()
+	g.armTimer()
+	g.persist()
+
+	g.publishUpdate(Update{Type: UpdateSwap, Move: &entry, Seat: playerNum})
+	return nil
+}
+
+// CanSwap reports whether the player to move may swap: the game uses the
+// swap rule and exactly one piece has been played.
+func CanSwap(game *models.Game) bool {
+	if game.Settings.Ruleset != models.RulesetSwap || game.CurrentTurn != 2 {
+		return false
+	}
+	return len(game.Moves) == 1 && game.Moves[0].Type == ""
+}
+
+// afterSwap returns the part of the move log after the last swap. Takebacks
+// never reach across a swap, since undoing the opening move would leave the
+// exchanged seats without a position to own.
+func afterSwap(moves []models.Move) []models.Move {
+	for i := len(moves) - 1; i >= 0; i-- {
+		if moves[i].Type == models.MoveSwap {
+			return moves[i+1:]
+		}
+	}
+	return moves
+}
@@ -0,0 +1,59 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func TestSwap(t *testing.T) {
+	swapRules := models.GameSettings{Ruleset: models.RulesetSwap}
+	tests := []struct {
+		name     string
+		settings models.GameSettings
+		moves    string
+		seat     int
+		wantErr  error
+	}{
+		{"after the first move", swapRules, "4", 2, nil},
+		{"standard rules", models.GameSettings{}, "4", 2, ErrSwapNotAllowed},
+		{"before any move", swapRules, "", 1, ErrSwapNotAllowed},
+		{"after the reply", swapRules, "44", 1, ErrSwapNotAllowed},
+		{"out of turn", swapRules, "4", 1, ErrNotYourTurn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestTwoPlayerGame(t, tt.settings, tt.moves)
+
+			err := g.Swap(tt.seat)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Swap(%d) = %v, want %v", tt.seat, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Swap(%d): %v", tt.seat, err)
+			}
+
+			// bob owns the opening piece and alice, now seat 2, replies
+			state := g.Snapshot()
+			if state.Player1.ID != "p2" || state.Player2.ID != "p1" || state.Player1.Piece != 1 || state.Player2.Piece != 2 {
+				t.Errorf("seats after the swap: %+v and %+v, want bob then alice", state.Player1, state.Player2)
+			}
+			if state.CurrentTurn != 2 {
+				t.Errorf("current turn = %d, want 2", state.CurrentTurn)
+			}
+			if last := state.Moves[len(state.Moves)-1]; last.Type != models.MoveSwap || last.Player != 2 {
+				t.Errorf("last log entry = %+v, want a swap by seat 2", last)
+			}
+			if CanSwap(state) {
+				t.Error("CanSwap holds after the swap")
+			}
+			if err := g.MakeMove(3, 2); err != nil {
+				t.Errorf("reply after the swap: %v", err)
+			}
+		})
+	}
+}
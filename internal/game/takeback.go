@@ -0,0 +1,155 @@
+package game
+
+import (
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// RequestTakeback asks to undo the player's last move. In practice games it
+// is granted at once; in casual games the opponent has to accept it.
+func (g *GameInstance) RequestTakeback(playerNum int) error {
+	return g.do(command{kind: cmdRequestTakeback, playerNum: playerNum}).err
+}
+
+// RespondTakeback answers the opponent's pending takeback request.
+func (g *GameInstance) RespondTakeback(playerNum int, accept bool) error {
+	return g.do(command{kind: cmdRespondTakeback, playerNum: playerNum, accept: accept}).err
+}
+
+func (g *GameInstance) applyTakebackRequest(playerNum int) error {
+	if g.state.Status != models.StatusPlaying {
+		return g.notPlaying()
+	}
+
+	mode := g.state.Settings.Mode
+	if mode != models.ModeCasual && mode != models.ModePractice {
+		return ErrTakebackNotAllowed
+	}
+	// Power-up effects and other players' turns cannot be wound back
+	if g.state.Settings.Ruleset == models.RulesetArcade || SeatCount(g.state) > 2 {
+		return ErrTakebackNotAllowed
+	}
+	if g.state.TakebackRequest != 0 {
+		return ErrTakebackPending
+	}
+	if !hasStandingMove(afterSwap(g.state.Moves), playerNum) {
+		return ErrNothingToTakeBack
+	}
+
+	if mode == models.ModePractice {
+		g.takeBack(playerNum)
+		return nil
+	}
+
+	g.state.TakebackRequest = playerNum
+	g.state.UpdatedAt = time.Now()
+	g.persist()
+	g.publishUpdate(Update{Type: UpdateTakebackRequested, Seat: playerNum})
+	return nil
+}
+
+func (g *GameInstance) applyTakebackResponse(playerNum int, accept bool) error {
+	if g.state.Status != models.StatusPlaying {
+		return g.notPlaying()
+	}
+
+	requester := g.state.TakebackRequest
+	if requester == 0 || requester == playerNum {
+		return ErrNoTakebackPending
+	}
+	g.state.TakebackRequest = 0
+
+	if accept {
+		g.takeBack(requester)
+		return nil
+	}
+
+	g.state.UpdatedAt = time.Now()
+	g.persist()
+	g.publishUpdate(Update{Type: UpdateTakebackDeclined, Seat: requester})
+	return nil
+}
+
+// takeBack removes moves from the top of the game until the requester's
+// most recent move is gone, so it is the requester's turn again. Each
+// removed piece is recorded as a takeback entry in the move log.
+func (g *GameInstance) takeBack(requester int) {
+	var undone []models.Move
+	for {
+		last, ok := lastStandingMove(afterSwap(g.state.Moves))
+		if !ok {
+			break
+		}
+
+		row, player, _ := g.board.UnmakeMove(last.Column)
+		entry := models.Move{
+			GameID: g.ID,
+			Column: last.Column,
+			Row:    row,
+			Player: player,
+			Type:   models.MoveTakeback,
+		}
+		g.state.Moves = append(g.state.Moves, entry)
+		undone = append(undone, entry)
+
+		if player == requester {
+			break
+		}
+	}
+
+	g.state.Board = g.board.GetGrid()
+	g.state.CurrentTurn = requester
+	g.state.TakebackRequest = 0
+	g.state.UpdatedAt = time.Now()
+	g.armTimer()
+	g.persist()
+	g.publishUpdate(Update{Type: UpdateTakeback, Seat: requester, Undone: undone})
+}
+
+// standingMoves returns the moves still on the board once takebacks in the
+// log are applied.
+func standingMoves(moves []models.Move) []models.Move {
+	var standing []models.Move
+	for _, move := range moves {
+		switch move.Type {
+		case models.MoveTakeback:
+			if len(standing) > 0 {
+				standing = standing[:len(standing)-1]
+			}
+		case models.MoveSwap:
+		default:
+			standing = append(standing, move)
+		}
+	}
+	return standing
+}
+
+// MoveCount returns the number of moves on the board, leaving out moves
+// that were taken back.
+func MoveCount(game *models.Game) int {
+	count := 0
+	for _, move := range standingMoves(game.Moves) {
+		if move.Type != models.MoveEliminated {
+			count++
+		}
+	}
+	return count
+}
+
+func lastStandingMove(moves []models.Move) (models.Move, bool) {
+	standing := standingMoves(moves)
+	if len(standing) == 0 {
+		return models.Move{}, false
+	}
+	return standing[len(standing)-1], true
+}
+
+func hasStandingMove(moves []models.Move, player int) bool {
+	for _, move := range standingMoves(moves) {
+		if move.Player == player {
+			return true
+		}
+	}
+	return false
+}
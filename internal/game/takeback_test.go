@@ -0,0 +1,147 @@
+package game
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+func TestTakeback(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.GameSettings
+		moves    string
+		// request is the seat asking; respond, if not 0, the seat answering.
+		request int
+		respond int
+		accept  bool
+		wantErr error
+		// wantFEN is the position afterwards, as seat wantPending waits
+		// on an answer.
+		wantFEN     string
+		wantPending int
+	}{
+		{
+			name:     "practice undoes the player's last move",
+			settings: models.GameSettings{Mode: models.ModePractice},
+			moves:    "445",
+			request:  1,
+			wantFEN:  "7/7/7/7/3o3/3x3 x",
+		},
+		{
+			name:     "practice undoes the reply too",
+			settings: models.GameSettings{Mode: models.ModePractice},
+			moves:    "445",
+			request:  2,
+			wantFEN:  "7/7/7/7/7/3x3 o",
+		},
+		{
+			name:        "casual waits for the opponent",
+			settings:    models.GameSettings{Mode: models.ModeCasual},
+			moves:       "445",
+			request:     1,
+			wantFEN:     "7/7/7/7/3o3/3xx2 o",
+			wantPending: 1,
+		},
+		{
+			name:     "casual accepted",
+			settings: models.GameSettings{Mode: models.ModeCasual},
+			moves:    "445",
+			request:  1,
+			respond:  2,
+			accept:   true,
+			wantFEN:  "7/7/7/7/3o3/3x3 x",
+		},
+		{
+			name:     "casual declined",
+			settings: models.GameSettings{Mode: models.ModeCasual},
+			moves:    "445",
+			request:  1,
+			respond:  2,
+			wantFEN:  "7/7/7/7/3o3/3xx2 o",
+		},
+		{
+			name:     "requester cannot answer",
+			settings: models.GameSettings{Mode: models.ModeCasual},
+			moves:    "445",
+			request:  1,
+			respond:  1,
+			accept:   true,
+			wantErr:  ErrNoTakebackPending,
+		},
+		{
+			name:    "rated",
+			moves:   "445",
+			request: 1,
+			wantErr: ErrTakebackNotAllowed,
+		},
+		{
+			name:     "arcade",
+			settings: models.GameSettings{Mode: models.ModeCasual, Ruleset: models.RulesetArcade},
+			moves:    "445",
+			request:  1,
+			wantErr:  ErrTakebackNotAllowed,
+		},
+		{
+			name:     "no move yet",
+			settings: models.GameSettings{Mode: models.ModePractice},
+			moves:    "4",
+			request:  2,
+			wantErr:  ErrNothingToTakeBack,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestTwoPlayerGame(t, tt.settings, tt.moves)
+
+			err := g.RequestTakeback(tt.request)
+			if err == nil && tt.respond != 0 {
+				err = g.RespondTakeback(tt.respond, tt.accept)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("takeback error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("takeback: %v", err)
+			}
+
+			state := g.Snapshot()
+			if fen := EncodeFEN(g.BoardSnapshot(), state.CurrentTurn); fen != tt.wantFEN {
+				t.Errorf("position = %q, want %q", fen, tt.wantFEN)
+			}
+			if state.TakebackRequest != tt.wantPending {
+				t.Errorf("pending request from seat %d, want %d", state.TakebackRequest, tt.wantPending)
+			}
+		})
+	}
+}
+
+// The move log keeps every takeback, and a second request winds back to the
+// requester's move before.
+func TestTakebackLog(t *testing.T) {
+	g := newTestTwoPlayerGame(t, models.GameSettings{Mode: models.ModePractice}, "4455")
+	for i := 0; i < 2; i++ {
+		if err := g.RequestTakeback(2); err != nil {
+			t.Fatalf("RequestTakeback %d: %v", i+1, err)
+		}
+	}
+
+	state := g.Snapshot()
+	var undone []int
+	for _, move := range state.Moves {
+		if move.Type == models.MoveTakeback {
+			undone = append(undone, move.Column+1)
+		}
+	}
+	if want := []int{5, 5, 4}; !reflect.DeepEqual(undone, want) {
+		t.Errorf("takeback entries for columns %v, want %v", undone, want)
+	}
+	if fen := EncodeFEN(g.BoardSnapshot(), state.CurrentTurn); fen != "7/7/7/7/7/3x3 o" {
+		t.Errorf("position = %q, want player 1's first move only", fen)
+	}
+}
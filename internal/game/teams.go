@@ -0,0 +1,88 @@
+package game
+
+import (
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Team games seat two teams of two on the standard board, alternating
+// between the teams: seat 1 (team 1), seat 2 (team 2), seat 3 (team 1), seat
+// 4 (team 2). Teammates share a colour, so the board and the move log look
+// like an ordinary two-player game apart from the Seat on each move.
+
+// NewTeamGame creates a team game. Each team lists its two players in the
+// order they move.
+func NewTeamGame(teams [2][2]*models.Player, settings models.GameSettings) (*GameInstance, error) {
+	settings.Seats = 4
+	settings.Teams = true
+	if _, _, _, err := StartingPosition(settings); err != nil {
+		return nil, err
+	}
+
+	seats := []*models.Player{teams[0][0], teams[1][0], teams[0][1], teams[1][1]}
+	for i, player := range seats {
+		player.Piece = TeamOf(i + 1)
+	}
+	board := NewBoard()
+
+	game := &models.Game{
+		ID:          uuid.New().String(),
+		Player1:     seats[0],
+		Player2:     seats[1],
+		Seats:       seats,
+		Board:       board.GetGrid(),
+		CurrentTurn: 1,
+		TurnSeat:    1,
+		Status:      models.StatusPlaying,
+		Moves:       []models.Move{},
+		Settings:    settings,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	return newInstance(game, board), nil
+}
+
+// TeamOf returns the team, and so the colour, of a seat in a team game.
+func TeamOf(seat int) int {
+	return (seat-1)%2 + 1
+}
+
+// Teammates returns the players on the given team of a team game.
+func Teammates(game *models.Game, team int) []*models.Player {
+	var members []*models.Player
+	for i, player := range game.Seats {
+		if TeamOf(i+1) == team {
+			members = append(members, player)
+		}
+	}
+	return members
+}
+
+// colours returns how many piece colours are on the board: one per player,
+// except in team games.
+func colours(game *models.Game) int {
+	if game.Settings.Teams {
+		return 2
+	}
+	return SeatCount(game)
+}
+
+// isFFA reports whether a game is a free-for-all of more than two players.
+func isFFA(game *models.Game) bool {
+	return colours(game) > 2
+}
+
+// teamSeat checks that seat is the teammate to move and returns the colour
+// it plays.
+func (g *GameInstance) teamSeat(seat int) (int, error) {
+	if seat < 1 || seat > SeatCount(g.state) {
+		return 0, ErrNotInGame
+	}
+	if seat != g.state.TurnSeat {
+		return 0, g.notYourTurn()
+	}
+	return TeamOf(seat), nil
+}
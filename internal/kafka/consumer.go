@@ -8,12 +8,18 @@ import (
     "github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
 )
 
+// Store keeps the totals the consumer aggregates from events.
+type Store interface {
+    IncrementEmoteCount(emote string) error
+}
+
 type Consumer struct {
     consumer sarama.ConsumerGroup
     topic    string
+    store    Store
 }
 
-func NewConsumer(brokers []string, groupID, topic string) (*Consumer, error) {
+func NewConsumer(brokers []string, groupID, topic string, store Store) (*Consumer, error) {
     config := sarama.NewConfig()
     config.Consumer.Group.Rebalance.Strategy = sarama.NewBalanceStrategyRoundRobin()
     config.Consumer.Offsets.Initial = sarama.OffsetNewest
@@ -27,11 +33,12 @@ func NewConsumer(brokers []string, groupID, topic string) (*Consumer, error) {
     return &Consumer{
         consumer: consumer,
         topic:    topic,
+        store:    store,
     }, nil
 }
 
 func (c *Consumer) Start(ctx context.Context) {
-    handler := &consumerHandler{}
+    handler := &consumerHandler{store: c.store}
     
     go func() {
         for {
@@ -50,7 +57,9 @@ func (c *Consumer) Close() error {
     return c.consumer.Close()
 }
 
-type consumerHandler struct{}
+type consumerHandler struct {
+    store Store
+}
 
 func (h *consumerHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
 func (h *consumerHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
@@ -67,9 +76,29 @@ func (h *consumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, clai
         log.Printf("Analytics Event - Type: %s, GameID: %s, Time: %v", 
             event.Type, event.GameID, event.Timestamp)
         
-        // Here you can store to database or process further
-        
+        if event.Type == "emote" {
+            h.countEmote(&event)
+        }
+
         session.MarkMessage(message, "")
     }
     return nil
-}
\ No newline at end of file
+}
+
+// countEmote adds a sent emote to the totals.
+func (h *consumerHandler) countEmote(event *models.GameEvent) {
+    // Data arrives as a generic map; round-trip it into the event type
+    raw, err := json.Marshal(event.Data)
+    if err != nil {
+        return
+    }
+    var emote models.EmoteEvent
+    if err := json.Unmarshal(raw, &emote); err != nil || emote.Emote == "" {
+        log.Printf("Malformed emote event in game %s", event.GameID)
+        return
+    }
+
+    if err := h.store.IncrementEmoteCount(emote.Emote); err != nil {
+        log.Printf("Failed to count emote %s: %v", emote.Emote, err)
+    }
+}
@@ -1,6 +1,7 @@
 package matchmaking
 
 import (
+    "sort"
     "sync"
     "time"
     "github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
@@ -9,6 +10,10 @@ import (
 
 type WaitingPlayer struct {
     Player    *models.Player
+    Settings  models.GameSettings
+    // Partner is the username of the teammate a player queued with for a
+    // team game, if any.
+    Partner   string
     Timestamp time.Time
     GameChan  chan *game.GameInstance
 }
@@ -24,14 +29,30 @@ func NewMatchmaker() *Matchmaker {
     }
 }
 
-func (m *Matchmaker) AddPlayer(player *models.Player) chan *game.GameInstance {
+// AddPlayer queues a player for a game with the given settings. Players are
+// only matched with others who asked for the same ruleset and number of
+// players.
+func (m *Matchmaker) AddPlayer(player *models.Player, settings models.GameSettings) chan *game.GameInstance {
+    return m.AddParty(player, settings, "")
+}
+
+// AddParty queues a player for a team game together with a partner. The two
+// are put on the same team once both have queued naming each other; until
+// then neither is matched with anyone else.
+func (m *Matchmaker) AddParty(player *models.Player, settings models.GameSettings, partner string) chan *game.GameInstance {
     m.mu.Lock()
     defer m.mu.Unlock()
 
     gameChan := make(chan *game.GameInstance, 1)
     
+    // Queueing again gives up the earlier place
+    if previous, ok := m.waiting[player.ID]; ok {
+        close(previous.GameChan)
+    }
     m.waiting[player.ID] = &WaitingPlayer{
         Player:    player,
+        Settings:  settings,
+        Partner:   partner,
         Timestamp: time.Now(),
         GameChan:  gameChan,
     }
@@ -39,51 +60,202 @@ func (m *Matchmaker) AddPlayer(player *models.Player) chan *game.GameInstance {
     return gameChan
 }
 
-func (m *Matchmaker) RemovePlayer(playerID string) {
+// RemovePlayer takes a player out of the queue and reports whether they were
+// still waiting. Their game channel is closed, so whoever waits on it knows
+// the search was given up rather than matched.
+func (m *Matchmaker) RemovePlayer(playerID string) bool {
     m.mu.Lock()
     defer m.mu.Unlock()
+
+    waiting, ok := m.waiting[playerID]
+    if !ok {
+        return false
+    }
+    close(waiting.GameChan)
     delete(m.waiting, playerID)
+    return true
+}
+
+// QueueLength returns the number of players waiting for a game.
+func (m *Matchmaker) QueueLength() int {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return len(m.waiting)
 }
 
-func (m *Matchmaker) TryMatch(playerID string) (*game.GameInstance, *models.Player) {
+// TryMatch starts a game for the player once enough compatible players are
+// waiting: one opponent for an ordinary game, or a whole group for a
+// free-for-all. Every matched player, the caller included, is sent the game
+// on their channel.
+func (m *Matchmaker) TryMatch(playerID string) *game.GameInstance {
     m.mu.Lock()
     defer m.mu.Unlock()
 
     wp, exists := m.waiting[playerID]
     if !exists {
-        return nil, nil
+        return nil
+    }
+
+    if wp.Settings.Teams {
+        return m.tryTeamMatch(wp)
     }
 
-    // Try to find another waiting player
+    // Longest waiting first, so nobody is passed over by later arrivals
+    var others []*WaitingPlayer
     for id, otherWP := range m.waiting {
-        if id != playerID {
-            // Found a match!
-            player1 := wp.Player
-            player2 := otherWP.Player
-            
-            player1.Piece = 1
-            player2.Piece = 2
-
-            newGame := game.NewGame(player1, false)
-            newGame.AddPlayer2(player2)
-
-            // Notify both players
-            wp.GameChan <- newGame
-            otherWP.GameChan <- newGame
-
-            // Remove both from waiting
-            delete(m.waiting, playerID)
-            delete(m.waiting, id)
-
-            return newGame, player2
+        if id != playerID && compatible(wp.Settings, otherWP.Settings) {
+            others = append(others, otherWP)
+        }
+    }
+    sort.Slice(others, func(i, j int) bool {
+        return others[i].Timestamp.Before(others[j].Timestamp)
+    })
+
+    group := []*WaitingPlayer{wp}
+    for _, otherWP := range others {
+        if len(group) == seatsWanted(wp.Settings) {
+            break
         }
+        group = append(group, otherWP)
+    }
+    if len(group) < seatsWanted(wp.Settings) {
+        return nil
+    }
+
+    players := make([]*models.Player, len(group))
+    for i, member := range group {
+        players[i] = member.Player
     }
 
-    return nil, nil
+    newGame, err := game.NewGroupGame(players, wp.Settings)
+    if err != nil {
+        return nil
+    }
+
+    m.notify(newGame, group)
+    return newGame
+}
+
+// tryTeamMatch puts together two teams for a team game: parties that queued
+// together play as a team, and players who queued alone are paired up.
+func (m *Matchmaker) tryTeamMatch(wp *WaitingPlayer) *game.GameInstance {
+    var candidates []*WaitingPlayer
+    for _, otherWP := range m.waiting {
+        if compatible(wp.Settings, otherWP.Settings) {
+            candidates = append(candidates, otherWP)
+        }
+    }
+    sort.Slice(candidates, func(i, j int) bool {
+        return candidates[i].Timestamp.Before(candidates[j].Timestamp)
+    })
+
+    // Split the queue into parties whose partner is here and solo players
+    var parties [][]*WaitingPlayer
+    var solos []*WaitingPlayer
+    grouped := make(map[string]bool)
+    for _, candidate := range candidates {
+        if grouped[candidate.Player.ID] {
+            continue
+        }
+        if candidate.Partner == "" {
+            solos = append(solos, candidate)
+            continue
+        }
+        for _, other := range candidates {
+            if other.Player.Username == candidate.Partner && other.Partner == candidate.Player.Username && !grouped[other.Player.ID] {
+                parties = append(parties, []*WaitingPlayer{candidate, other})
+                grouped[candidate.Player.ID] = true
+                grouped[other.Player.ID] = true
+                break
+            }
+        }
+    }
+
+    // Form the caller's team first, then find opponents
+    var teams [][]*WaitingPlayer
+    nextSolo := func() *WaitingPlayer {
+        for _, solo := range solos {
+            if !grouped[solo.Player.ID] {
+                grouped[solo.Player.ID] = true
+                return solo
+            }
+        }
+        return nil
+    }
+
+    if wp.Partner != "" {
+        for _, party := range parties {
+            if party[0] == wp || party[1] == wp {
+                teams = append(teams, party)
+            }
+        }
+        if len(teams) == 0 {
+            return nil
+        }
+    } else {
+        grouped[wp.Player.ID] = true
+        mate := nextSolo()
+        if mate == nil {
+            return nil
+        }
+        teams = append(teams, []*WaitingPlayer{wp, mate})
+    }
+
+    for _, party := range parties {
+        if party[0] != teams[0][0] && party[1] != teams[0][0] {
+            teams = append(teams, party)
+            break
+        }
+    }
+    if len(teams) < 2 {
+        first, second := nextSolo(), nextSolo()
+        if first == nil || second == nil {
+            return nil
+        }
+        teams = append(teams, []*WaitingPlayer{first, second})
+    }
+
+    newGame, err := game.NewTeamGame([2][2]*models.Player{
+        {teams[0][0].Player, teams[0][1].Player},
+        {teams[1][0].Player, teams[1][1].Player},
+    }, wp.Settings)
+    if err != nil {
+        return nil
+    }
+
+    m.notify(newGame, append(teams[0], teams[1]...))
+    return newGame
+}
+
+// notify sends the new game to everyone in it and takes them out of the
+// queue.
+func (m *Matchmaker) notify(newGame *game.GameInstance, group []*WaitingPlayer) {
+    for _, member := range group {
+        member.GameChan <- newGame
+        delete(m.waiting, member.Player.ID)
+    }
 }
 
 func (m *Matchmaker) GetWaitingPlayer(playerID string) *WaitingPlayer {
     m.mu.Lock()
     defer m.mu.Unlock()
     return m.waiting[playerID]
-}
\ No newline at end of file
+}
+
+// compatible reports whether two waiting players want the same kind of game.
+func compatible(a, b models.GameSettings) bool {
+    ruleset := func(s models.GameSettings) string {
+        if s.Ruleset == "" {
+            return models.RulesetStandard
+        }
+        return s.Ruleset
+    }
+    return ruleset(a) == ruleset(b) && seatsWanted(a) == seatsWanted(b) && a.Teams == b.Teams
+}
+
+func seatsWanted(settings models.GameSettings) int {
+    if settings.Seats > 2 {
+        return settings.Seats
+    }
+    return 2
+}
@@ -0,0 +1,323 @@
+package websocket
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Chat comes in three channels: the players of a game talk to each other,
+// its spectators to each other, and in a team game each team has its own.
+// Spectators can read the players' channel but players never see the
+// spectators'. Every message is length-checked, rate limited per player,
+// run through the word filter and logged with its game.
+
+// maxChatLength caps the length of a chat message in characters.
+const maxChatLength = 500
+
+// validateChatText trims a chat message and checks its length.
+func validateChatText(text *string) error {
+	*text = strings.TrimSpace(*text)
+	if *text == "" {
+		return invalidPayload("text is required")
+	}
+	if len([]rune(*text)) > maxChatLength {
+		return &ProtocolError{
+			Code:    CodeInvalidPayload,
+			Message: "message is too long",
+			Details: map[string]interface{}{"max_length": maxChatLength},
+		}
+	}
+	return nil
+}
+
+// rateLimiter allows up to limit events in any window. It is used under the
+// lock of the socialState it belongs to.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+	sent   []time.Time
+}
+
+// allow records an event at now if the limit allows it. Otherwise it returns
+// how long until the next event would be allowed.
+func (r *rateLimiter) allow(now time.Time) (time.Duration, bool) {
+	for len(r.sent) > 0 && now.Sub(r.sent[0]) >= r.window {
+		r.sent = r.sent[1:]
+	}
+	if len(r.sent) >= r.limit {
+		return r.sent[0].Add(r.window).Sub(now), false
+	}
+	r.sent = append(r.sent, now)
+	return 0, true
+}
+
+// wordFilter masks configured words in chat messages. Words match whole and
+// regardless of case.
+type wordFilter struct {
+	pattern *regexp.Regexp
+}
+
+func newWordFilter(words []string) *wordFilter {
+	var quoted []string
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return &wordFilter{}
+	}
+	return &wordFilter{pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+// apply returns the text with every filtered word replaced by asterisks.
+func (f *wordFilter) apply(text string) string {
+	if f.pattern == nil {
+		return text
+	}
+	return f.pattern.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	})
+}
+
+// socialState is what a player has chosen to hide, and how fast they have
+// been chatting and sending emotes. It is kept by player ID rather than on
+// the connection, so it outlives a reconnect.
+type socialState struct {
+	mu         sync.Mutex
+	muted      map[string]bool
+	blocked    map[string]bool
+	hideEmotes bool
+	// chatLimiter paces the player's chat messages, and emoteLimiter their
+	// emotes. They are made on first use.
+	chatLimiter  *rateLimiter
+	emoteLimiter *rateLimiter
+	seen         time.Time
+}
+
+// hides reports whether the player has muted or blocked another.
+func (s *socialState) hides(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.muted[username] || s.blocked[username]
+}
+
+func (s *socialState) setMuted(username string, muted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if muted {
+		s.muted[username] = true
+	} else {
+		delete(s.muted, username)
+	}
+}
+
+func (s *socialState) setBlocked(username string, blocked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if blocked {
+		s.blocked[username] = true
+	} else {
+		delete(s.blocked, username)
+	}
+}
+
+// allow checks one of the player's rate limits, making the limiter with
+// newLimiter the first time.
+func (s *socialState) allow(limiter **rateLimiter, newLimiter func() *rateLimiter) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if *limiter == nil {
+		*limiter = newLimiter()
+	}
+	return (*limiter).allow(time.Now())
+}
+
+// chatSettings lists who the player has muted and blocked.
+func (s *socialState) chatSettings() *ChatSettingsMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings := &ChatSettingsMessage{Muted: []string{}, Blocked: []string{}}
+	for username := range s.muted {
+		settings.Muted = append(settings.Muted, username)
+	}
+	for username := range s.blocked {
+		settings.Blocked = append(settings.Blocked, username)
+	}
+	sort.Strings(settings.Muted)
+	sort.Strings(settings.Blocked)
+	return settings
+}
+
+// socialStates holds the social state of every player by player ID.
+type socialStates struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	players map[string]*socialState
+}
+
+func newSocialStates(ttl time.Duration) *socialStates {
+	return &socialStates{ttl: ttl, players: make(map[string]*socialState)}
+}
+
+// get returns a player's social state, starting an empty one if need be.
+// The state of players who have been gone longer than ttl, as connected
+// reports, is dropped at the same time.
+func (r *socialStates) get(playerID string, connected func(playerID string) bool) *socialState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	state, ok := r.players[playerID]
+	if !ok {
+		for id, other := range r.players {
+			if now.Sub(other.seen) > r.ttl && !connected(id) {
+				delete(r.players, id)
+			}
+		}
+		state = &socialState{muted: make(map[string]bool), blocked: make(map[string]bool)}
+		r.players[playerID] = state
+	}
+	state.seen = now
+	return state
+}
+
+// social returns the social state of the player a client plays as.
+func (h *Handler) social(client *Client) *socialState {
+	return h.socials.get(client.ID(), func(playerID string) bool {
+		return h.hub.GetClient(playerID) != nil
+	})
+}
+
+// handleChat posts a message to the channel the client belongs to: the
+// spectators' channel of the game it watches, or else the players' channel
+// of the game it plays in.
+func (h *Handler) handleChat(client *Client, req *ChatRequest) error {
+	if gameID := h.spectators.gameOf(client); gameID != "" {
+		if !h.config.SpectatorChat {
+			return &ProtocolError{Code: CodeChatDisabled, Message: "spectator chat is turned off"}
+		}
+		entry, err := h.postChat(client, gameID, models.ChatSpectators, 0, req.Text)
+		if err != nil {
+			return err
+		}
+		h.deliverChat(h.spectators.list(gameID), entry.From, MsgChat, chatMessage(entry))
+		return nil
+	}
+
+	gameInstance, seat, err := h.playerGame(client)
+	if err != nil {
+		return err
+	}
+	snapshot := gameInstance.Snapshot()
+	if snapshot == nil {
+		return game.ErrGameClosed
+	}
+
+	entry, err := h.postChat(client, snapshot.ID, models.ChatPlayers, seat, req.Text)
+	if err != nil {
+		return err
+	}
+	recipients := append(h.spectators.list(snapshot.ID), h.connectedPlayers(snapshot)...)
+	h.deliverChat(recipients, entry.From, MsgChat, chatMessage(entry))
+	return nil
+}
+
+// postChat checks the client's rate limit, filters the text and logs the
+// message with its game.
+func (h *Handler) postChat(client *Client, gameID, channel string, seat int, text string) (*models.ChatMessage, error) {
+	if h.config.ChatRateLimit > 0 {
+		social := h.social(client)
+		wait, ok := social.allow(&social.chatLimiter, func() *rateLimiter {
+			return &rateLimiter{limit: h.config.ChatRateLimit, window: h.config.ChatRateWindow}
+		})
+		if !ok {
+			return nil, &ProtocolError{
+				Code:    CodeChatRateLimited,
+				Message: "sending messages too fast, slow down",
+				Details: map[string]interface{}{"retry_after_ms": wait.Milliseconds()},
+			}
+		}
+	}
+
+	entry := &models.ChatMessage{
+		GameID:  gameID,
+		Channel: channel,
+		From:    client.username,
+		Seat:    seat,
+		Text:    h.chatFilter.apply(text),
+		SentAt:  time.Now(),
+	}
+	if entry.Text != text {
+		entry.Original = text
+	}
+	if err := h.db.SaveChatMessage(entry); err != nil {
+		log.Printf("Failed to log chat in game %s: %v", gameID, err)
+	}
+	return entry, nil
+}
+
+// deliverChat sends a chat message to the recipients that have not muted or
+// blocked its sender.
+func (h *Handler) deliverChat(recipients []*Client, from, msgType string, payload interface{}) {
+	for _, recipient := range recipients {
+		if !h.social(recipient).hides(from) {
+			recipient.Send(msgType, payload)
+		}
+	}
+}
+
+func chatMessage(entry *models.ChatMessage) *ChatMessage {
+	return &ChatMessage{
+		Channel: entry.Channel,
+		From:    entry.From,
+		Seat:    entry.Seat,
+		Text:    entry.Text,
+		SentAt:  entry.SentAt,
+	}
+}
+
+// handleMute hides or shows a player's chat. Mutes last across reconnects
+// but not server restarts.
+func (h *Handler) handleMute(client *Client, username string, muted bool) error {
+	social := h.social(client)
+	social.setMuted(username, muted)
+	client.Send(MsgChatSettings, social.chatSettings())
+	return nil
+}
+
+// handleBlock hides a player's chat on every connection from now on, or
+// lifts the block. Unblocking also unmutes.
+func (h *Handler) handleBlock(client *Client, username string, blocked bool) error {
+	if err := h.db.SetBlocked(client.username, username, blocked); err != nil {
+		return err
+	}
+	social := h.social(client)
+	social.setBlocked(username, blocked)
+	if !blocked {
+		social.setMuted(username, false)
+	}
+	client.Send(MsgChatSettings, social.chatSettings())
+	return nil
+}
+
+// loadBlocked restores the players a client's user has blocked.
+func (h *Handler) loadBlocked(client *Client) {
+	blocked, err := h.db.GetBlockedPlayers(client.username)
+	if err != nil {
+		log.Printf("Failed to load blocked players for %s: %v", client.username, err)
+		return
+	}
+	social := h.social(client)
+	for _, username := range blocked {
+		social.setBlocked(username, true)
+	}
+}
@@ -1,8 +1,9 @@
 package websocket
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
+	"sync"
 	"time"
 	"github.com/gorilla/websocket"
 )
@@ -14,13 +15,31 @@ const (
 	maxMessageSize = 512
 )
 
+// errClientClosed is returned for messages to a client whose connection is
+// closing.
+var errClientClosed = errors.New("client connection is closed")
+
 type Client struct {
-	id       string
 	hub      *Hub
 	conn     *websocket.Conn
 	send     chan []byte
 	username string
-	gameID   string
+
+	// idMu guards id and gameID, which matchmaking, timers and rejoins
+	// change while the client's own goroutines read them.
+	idMu   sync.RWMutex
+	id     string
+	gameID string
+
+	// protocol is the wire protocol version negotiated at connect.
+	protocol int
+	// compact is set for clients that asked for compact game messages.
+	compact bool
+
+	// sendMu guards closing send, which happens once, when the client
+	// disconnects or falls behind.
+	sendMu sync.Mutex
+	closed bool
 }
 
 func NewClient(id, username string, hub *Hub, conn *websocket.Conn) *Client {
@@ -30,16 +49,43 @@ func NewClient(id, username string, hub *Hub, conn *websocket.Conn) *Client {
 		conn:     conn,
 		send:     make(chan []byte, 256),
 		username: username,
+		protocol: negotiateProtocol(conn.Subprotocol()),
 	}
 }
 
+// ID returns the player ID the client plays as.
+func (c *Client) ID() string {
+	c.idMu.RLock()
+	defer c.idMu.RUnlock()
+	return c.id
+}
+
+func (c *Client) setID(id string) {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	c.id = id
+}
+
+// GameID returns the game the client plays in, or the last one it played.
+func (c *Client) GameID() string {
+	c.idMu.RLock()
+	defer c.idMu.RUnlock()
+	return c.gameID
+}
+
+func (c *Client) setGameID(gameID string) {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	c.gameID = gameID
+}
+
 func (c *Client) readPump(handleMessage func(*Client, []byte)) {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
 		
 		// Log disconnection for debugging
-		log.Printf("Client disconnected: %s (username: %s)", c.id, c.username)
+		log.Printf("Client disconnected: %s (username: %s)", c.ID(), c.username)
 	}()
 
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -96,17 +142,73 @@ func (c *Client) writePump() {
 	}
 }
 
-func (c *Client) SendJSON(v interface{}) error {
-	data, err := json.Marshal(v)
+// Send queues a message of the given type in the client's protocol.
+func (c *Client) Send(msgType string, payload interface{}) error {
+	return c.Reply("", msgType, payload)
+}
+
+// Reply is Send for a message answering the request with the given ID.
+func (c *Client) Reply(id, msgType string, payload interface{}) error {
+	if c.compact {
+		payload = compactPayload(payload)
+	}
+	data, err := encodeMessage(c.protocol, msgType, id, 0, payload)
+	if err != nil {
+		return err
+	}
+	return c.queue(data)
+}
+
+// SendEvent queues a game event with its sequence number.
+func (c *Client) SendEvent(seq int64, msgType string, payload interface{}) error {
+	if c.compact {
+		payload = compactPayload(payload)
+	}
+	data, err := encodeMessage(c.protocol, msgType, "", seq, payload)
 	if err != nil {
 		return err
 	}
-	
+	return c.queue(data)
+}
+
+// queue hands an encoded message to the write pump. A client whose buffer
+// is full has fallen too far behind to be caught up message by message, so
+// rather than lose the message its connection is closed; it picks up from
+// its last game event when it reconnects.
+func (c *Client) queue(data []byte) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return errClientClosed
+	}
 	select {
 	case c.send <- data:
+		return nil
 	default:
-		log.Printf("Failed to send message to client %s: channel full", c.username)
+		log.Printf("Closing connection to %s: send buffer full", c.username)
+		c.closeSendLocked()
+		return errClientClosed
 	}
-	
-	return nil
-}
\ No newline at end of file
+}
+
+// closeSend stops the write pump, which closes the connection. It is safe
+// to call more than once.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	c.closeSendLocked()
+}
+
+func (c *Client) closeSendLocked() {
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// SendError reports a failed request to the client. id is the request's ID,
+// or empty for errors that answer no particular request.
+func (c *Client) SendError(id string, err error) error {
+	return c.Reply(id, MsgError, errorMessage(err))
+}
@@ -0,0 +1,179 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Clients that connect with compact=true are sent less. The whole game is
+// only sent when a client needs to catch up: on joining, spectating,
+// rejoining and resyncing, and then its board is a board string (see
+// game.EncodeBoard) rather than nested arrays. Every other game event
+// carries what it changed instead: the cells a move changed, the pieces a
+// takeback removed, the seats after a swap, and the state below.
+// Everything else is as for other clients.
+
+// CompactGame is a game with its board written as a board string.
+type CompactGame struct {
+	*models.Game
+	Board string `json:"board"`
+}
+
+// CompactState is what an event leaves of the game besides its board and
+// players: whose move it is, until when, and how many moves the log holds,
+// so a client can tell it has them all.
+type CompactState struct {
+	Status          models.GameStatus `json:"status"`
+	Moves           int               `json:"moves"`
+	CurrentTurn     int               `json:"current_turn"`
+	TurnSeat        int               `json:"turn_seat,omitempty"`
+	TurnDeadline    *time.Time        `json:"turn_deadline,omitempty"`
+	TakebackRequest int               `json:"takeback_request,omitempty"`
+}
+
+// CompactMoveMessage is MoveMessage for compact clients.
+type CompactMoveMessage struct {
+	Column  int                 `json:"column"`
+	Row     int                 `json:"row"`
+	Player  int                 `json:"player"`
+	Changes []models.CellChange `json:"changes"`
+	PowerUp string              `json:"powerup,omitempty"`
+	CompactState
+	// Inventory is the power-ups left after a power-up.
+	Inventory []models.Inventory `json:"inventory,omitempty"`
+}
+
+// CompactTakebackMessage is TakebackMessage for compact clients. Undone
+// lists the cells the takeback emptied.
+type CompactTakebackMessage struct {
+	Seat   int           `json:"seat"`
+	Undone []models.Move `json:"undone,omitempty"`
+	CompactState
+}
+
+// CompactEliminatedMessage is EliminatedMessage for compact clients.
+type CompactEliminatedMessage struct {
+	Seat       int                `json:"seat"`
+	Reason     string             `json:"reason"`
+	Placements []models.Placement `json:"placements"`
+	CompactState
+}
+
+// CompactSwapMessage is SwapMessage for compact clients, with the players in
+// their new seats.
+type CompactSwapMessage struct {
+	Seat    int            `json:"seat"`
+	Player1 *models.Player `json:"player1"`
+	Player2 *models.Player `json:"player2"`
+	CompactState
+}
+
+// CompactGameEndMessage is GameEndMessage for compact clients.
+type CompactGameEndMessage struct {
+	Result      string             `json:"result"`
+	Winner      *models.Player     `json:"winner"`
+	EndReason   string             `json:"end_reason,omitempty"`
+	WinningTeam int                `json:"winning_team,omitempty"`
+	Placements  []models.Placement `json:"placements,omitempty"`
+	FinishedAt  *time.Time         `json:"finished_at,omitempty"`
+	CompactState
+}
+
+// The messages below bring the whole game and are sent to compact clients
+// with its board as a board string.
+type (
+	compactGameStart struct {
+		*GameStartMessage
+		Game *CompactGame `json:"game"`
+	}
+	compactSpectateStart struct {
+		*SpectateStartMessage
+		Game *CompactGame `json:"game"`
+	}
+	compactRejoin struct {
+		*RejoinMessage
+		Game *CompactGame `json:"game,omitempty"`
+	}
+	compactResync struct {
+		*ResyncMessage
+		Game *CompactGame `json:"game"`
+	}
+)
+
+func compactGame(g *models.Game) *CompactGame {
+	if g == nil {
+		return nil
+	}
+	return &CompactGame{Game: g, Board: game.EncodeBoard(g.Board)}
+}
+
+func compactState(g *models.Game) CompactState {
+	return CompactState{
+		Status:          g.Status,
+		Moves:           len(g.Moves),
+		CurrentTurn:     g.CurrentTurn,
+		TurnSeat:        g.TurnSeat,
+		TurnDeadline:    g.TurnDeadline,
+		TakebackRequest: g.TakebackRequest,
+	}
+}
+
+// compactPayload returns a message as it is sent to compact clients.
+func compactPayload(payload interface{}) interface{} {
+	switch msg := payload.(type) {
+	case *MoveMessage:
+		compact := &CompactMoveMessage{
+			Column:       msg.Column,
+			Row:          msg.Row,
+			Player:       msg.Player,
+			Changes:      msg.Changes,
+			PowerUp:      msg.PowerUp,
+			CompactState: compactState(msg.Game),
+		}
+		if msg.PowerUp != "" {
+			compact.Inventory = msg.Game.Inventory
+		}
+		return compact
+	case *TakebackMessage:
+		return &CompactTakebackMessage{
+			Seat:         msg.Seat,
+			Undone:       msg.Undone,
+			CompactState: compactState(msg.Game),
+		}
+	case *EliminatedMessage:
+		return &CompactEliminatedMessage{
+			Seat:         msg.Seat,
+			Reason:       msg.Reason,
+			Placements:   msg.Game.Placements,
+			CompactState: compactState(msg.Game),
+		}
+	case *SwapMessage:
+		return &CompactSwapMessage{
+			Seat:         msg.Seat,
+			Player1:      msg.Game.Player1,
+			Player2:      msg.Game.Player2,
+			CompactState: compactState(msg.Game),
+		}
+	case *GameEndMessage:
+		return &CompactGameEndMessage{
+			Result:       msg.Result,
+			Winner:       msg.Winner,
+			EndReason:    msg.Game.EndReason,
+			WinningTeam:  msg.Game.WinningTeam,
+			Placements:   msg.Game.Placements,
+			FinishedAt:   msg.Game.FinishedAt,
+			CompactState: compactState(msg.Game),
+		}
+	case *GameStartMessage:
+		return &compactGameStart{msg, compactGame(msg.Game)}
+	case *SpectateStartMessage:
+		return &compactSpectateStart{msg, compactGame(msg.Game)}
+	case *RejoinMessage:
+		return &compactRejoin{msg, compactGame(msg.Game)}
+	case *ResyncMessage:
+		return &compactResync{msg, compactGame(msg.Game)}
+	}
+	return payload
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Compact clients are sent the whole game only when they need to catch up;
+// every other event carries just what it changed.
+func TestCompactPayload(t *testing.T) {
+	g := &models.Game{
+		ID:          "g1",
+		Player1:     &models.Player{ID: "p1", Username: "alice", Piece: 1},
+		Player2:     &models.Player{ID: "p2", Username: "bob", Piece: 2},
+		Board:       [][]int{{0, 0}, {1, 2}},
+		CurrentTurn: 1,
+		Status:      models.StatusPlaying,
+		Moves:       []models.Move{{Column: 0, Row: 1, Player: 1}, {Column: 1, Row: 1, Player: 2}},
+	}
+	tests := []struct {
+		name    string
+		payload interface{}
+		// wantGame is whether the whole game is sent; want lists fields
+		// the message must have either way.
+		wantGame bool
+		want     map[string]interface{}
+	}{
+		{"move", &MoveMessage{Column: 1, Row: 1, Player: 2, Game: g}, false, map[string]interface{}{"moves": 2.0, "current_turn": 1.0, "status": "playing"}},
+		{"takeback", &TakebackMessage{Seat: 1, Undone: g.Moves[1:], Game: g}, false, map[string]interface{}{"seat": 1.0, "moves": 2.0}},
+		{"elimination", &EliminatedMessage{Seat: 2, Reason: "resign", Game: g}, false, map[string]interface{}{"reason": "resign", "moves": 2.0}},
+		{"swap", &SwapMessage{Seat: 2, Game: g}, false, map[string]interface{}{"seat": 2.0, "current_turn": 1.0}},
+		{"end", &GameEndMessage{Result: "win", Winner: g.Player1, Game: g}, false, map[string]interface{}{"result": "win", "moves": 2.0}},
+		{"start", &GameStartMessage{Seat: 1, Game: g}, true, map[string]interface{}{"seat": 1.0}},
+		{"resync", &ResyncMessage{Game: g}, true, nil},
+		{"rejoin without a game", &RejoinMessage{}, false, nil},
+		{"not a game message", &SpectatorCountMessage{GameID: "g1", Spectators: 3}, false, map[string]interface{}{"spectators": 3.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(compactPayload(tt.payload))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+
+			sent, ok := fields["game"].(map[string]interface{})
+			if ok != tt.wantGame {
+				t.Fatalf("game sent = %v, want %v: %s", ok, tt.wantGame, data)
+			}
+			if ok && sent["board"] != "../12" {
+				t.Errorf("board = %v, want a board string", sent["board"])
+			}
+			for key, want := range tt.want {
+				if fields[key] != want {
+					t.Errorf("%s = %v, want %v: %s", key, fields[key], want, data)
+				}
+			}
+		})
+	}
+}
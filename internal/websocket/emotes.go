@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Emotes are quick reactions from a fixed set, a safer alternative to chat.
+// A player can send one per cooldown, and anyone can choose not to see them.
+// Every emote sent is also an analytics event, so usage can be counted.
+
+func isEmote(emote string) bool {
+	for _, known := range models.Emotes {
+		if emote == known {
+			return true
+		}
+	}
+	return false
+}
+
+// showsEmotes reports whether the player wants to see emotes.
+func (s *socialState) showsEmotes() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.hideEmotes
+}
+
+func (s *socialState) setShowEmotes(show bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hideEmotes = !show
+}
+
+// handleEmote sends an emote to the players and spectators of the sender's
+// game, leaving out those who opted out or muted the sender.
+func (h *Handler) handleEmote(client *Client, req *EmoteRequest) error {
+	gameInstance, seat, err := h.playerGame(client)
+	if err != nil {
+		return err
+	}
+	snapshot := gameInstance.Snapshot()
+	if snapshot == nil {
+		return game.ErrGameClosed
+	}
+
+	if h.config.EmoteCooldown > 0 {
+		social := h.social(client)
+		wait, ok := social.allow(&social.emoteLimiter, func() *rateLimiter {
+			return &rateLimiter{limit: 1, window: h.config.EmoteCooldown}
+		})
+		if !ok {
+			return &ProtocolError{
+				Code:    CodeEmoteCooldown,
+				Message: "wait a moment before sending another emote",
+				Details: map[string]interface{}{"retry_after_ms": wait.Milliseconds()},
+			}
+		}
+	}
+
+	msg := &EmoteMessage{From: client.username, Seat: seat, Emote: req.Emote}
+	recipients := append(h.spectators.list(snapshot.ID), h.connectedPlayers(snapshot)...)
+	for _, recipient := range recipients {
+		if social := h.social(recipient); social.showsEmotes() && !social.hides(client.username) {
+			recipient.Send(MsgEmote, msg)
+		}
+	}
+
+	h.kafkaProducer.SendGameEvent(&models.GameEvent{
+		Type:   MsgEmote,
+		GameID: snapshot.ID,
+		Data: &models.EmoteEvent{
+			Emote:    req.Emote,
+			Username: client.username,
+			Seat:     seat,
+		},
+		Timestamp: time.Now(),
+	})
+	return nil
+}
+
+// handleSetEmotes turns emotes on or off for the client's player.
+func (h *Handler) handleSetEmotes(client *Client, req *SetEmotesRequest) error {
+	h.social(client).setShowEmotes(req.Enabled)
+	return nil
+}
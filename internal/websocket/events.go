@@ -0,0 +1,238 @@
+package websocket
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// The events of a game that its players and spectators all receive, from
+// moves and takebacks to the spectator count and the end, are numbered from
+// 1 per game. Clients ack the events they have applied and, when they
+// rejoin, give the last one they saw: the server replays what came after
+// it, or sends the whole game if those events are no longer held. A client
+// that spots a gap can ask for the whole game at any time. Chat, emotes and
+// offers are not part of the sequence.
+
+// maxReplayEvents caps the events held per game for replay. A client further
+// behind than this is sent the whole game.
+const maxReplayEvents = 256
+
+type gameEvent struct {
+	seq     int64
+	msgType string
+	payload interface{}
+}
+
+// gameLog is the numbered event stream of one game. Events are numbered and
+// sent under mu, so every client gets them in order.
+type gameLog struct {
+	mu  sync.Mutex
+	seq int64
+	// events holds the latest events, oldest first. Events every player has
+	// acked are let go early.
+	events []gameEvent
+	// state is the game as of the last event that changed it.
+	state *models.Game
+	// acked is the last event each player acked, by player ID.
+	acked map[string]int64
+}
+
+// appendLocked numbers an event and keeps it for replay. state is the game
+// as the event leaves it, or nil for events that do not change it.
+func (l *gameLog) appendLocked(state *models.Game, msgType string, payload interface{}) gameEvent {
+	l.seq++
+	if state != nil {
+		l.state = state
+	}
+	event := gameEvent{seq: l.seq, msgType: msgType, payload: payload}
+	l.events = append(l.events, event)
+	l.trimLocked()
+	return event
+}
+
+// sinceLocked returns the events after seq, or false if some of them are no
+// longer held. A seq from before a restart may be ahead of the log, which
+// starts again from 0.
+func (l *gameLog) sinceLocked(seq int64) ([]gameEvent, bool) {
+	if seq > l.seq {
+		return nil, false
+	}
+	if seq == l.seq {
+		return nil, true
+	}
+	if len(l.events) == 0 || l.events[0].seq > seq+1 {
+		return nil, false
+	}
+	return l.events[seq+1-l.events[0].seq:], true
+}
+
+// ack records how far a player has got.
+func (l *gameLog) ack(playerID string, seq int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if seq > l.seq {
+		seq = l.seq
+	}
+	if seq > l.acked[playerID] {
+		l.acked[playerID] = seq
+		l.trimLocked()
+	}
+}
+
+// trimLocked lets go of the oldest events beyond maxReplayEvents and of
+// those every human player has acked.
+func (l *gameLog) trimLocked() {
+	keep := len(l.events)
+	if keep > maxReplayEvents {
+		keep = maxReplayEvents
+	}
+	acked := l.ackedByAllLocked()
+	for keep > 0 && l.events[len(l.events)-keep].seq <= acked {
+		keep--
+	}
+	if keep < len(l.events) {
+		l.events = append([]gameEvent(nil), l.events[len(l.events)-keep:]...)
+	}
+}
+
+// ackedByAllLocked returns the last event every human player has acked.
+func (l *gameLog) ackedByAllLocked() int64 {
+	if l.state == nil {
+		return 0
+	}
+	acked := l.seq
+	for _, player := range game.Players(l.state) {
+		if !strings.HasPrefix(player.ID, "bot-") && l.acked[player.ID] < acked {
+			acked = l.acked[player.ID]
+		}
+	}
+	return acked
+}
+
+// gameLogs holds the event log of every game the manager holds.
+type gameLogs struct {
+	mu    sync.Mutex
+	games map[string]*gameLog
+}
+
+func newGameLogs() *gameLogs {
+	return &gameLogs{games: make(map[string]*gameLog)}
+}
+
+// open starts the log of a game as it stands. Logs of games that are gone,
+// as live reports, are dropped at the same time.
+func (r *gameLogs) open(state *models.Game, live func(gameID string) bool) *gameLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for gameID := range r.games {
+		if gameID != state.ID && !live(gameID) {
+			delete(r.games, gameID)
+		}
+	}
+	l := r.getLocked(state.ID)
+	l.mu.Lock()
+	if l.state == nil {
+		l.state = state
+	}
+	l.mu.Unlock()
+	return l
+}
+
+// get returns the log of a game, starting an empty one if need be.
+func (r *gameLogs) get(gameID string) *gameLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.getLocked(gameID)
+}
+
+func (r *gameLogs) getLocked(gameID string) *gameLog {
+	l, ok := r.games[gameID]
+	if !ok {
+		l = &gameLog{acked: make(map[string]int64)}
+		r.games[gameID] = l
+	}
+	return l
+}
+
+// eventPayload returns an event as a client should see it. A swap tells each
+// player their new seat.
+func eventPayload(event gameEvent, client *Client) interface{} {
+	if swap, ok := event.payload.(*SwapMessage); ok {
+		if seat := game.SeatOf(swap.Game, client.ID()); seat != 0 {
+			personal := *swap
+			personal.Seat = seat
+			return &personal
+		}
+	}
+	return event.payload
+}
+
+// sendToGame numbers an event of a game and delivers it to every connected
+// player and spectator. state is the game as the event leaves it, or nil
+// for events that do not change it.
+func (h *Handler) sendToGame(gameID string, state *models.Game, msgType string, payload interface{}) {
+	l := h.events.get(gameID)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event := l.appendLocked(state, msgType, payload)
+	var recipients []*Client
+	if l.state != nil {
+		for _, player := range game.Players(l.state) {
+			// A player who has moved on to another game is not sent this one
+			if playerClient := h.hub.GetClient(player.ID); playerClient != nil && playerClient.GameID() == gameID {
+				recipients = append(recipients, playerClient)
+			}
+		}
+	}
+	recipients = append(recipients, h.spectators.list(gameID)...)
+	for _, recipient := range recipients {
+		recipient.SendEvent(event.seq, event.msgType, eventPayload(event, recipient))
+	}
+}
+
+// handleAck records a player's progress through their game's events.
+// Spectators ack too, but nothing is kept for them.
+func (h *Handler) handleAck(client *Client, req *AckRequest) error {
+	gameID := client.GameID()
+	if gameID == "" || h.spectators.gameOf(client) != "" || !h.isLive(gameID) {
+		return nil
+	}
+	h.events.get(gameID).ack(client.ID(), req.Seq)
+	return nil
+}
+
+// handleResync sends a client the whole of the game it plays or watches.
+// Events sent after it follow on from its Seq.
+func (h *Handler) handleResync(client *Client) error {
+	gameID := h.spectators.gameOf(client)
+	if gameID == "" {
+		gameID = client.GameID()
+	}
+	if gameID == "" {
+		return game.ErrNotInGame
+	}
+	if _, exists := h.gameManager.GetGame(gameID); !exists {
+		return game.ErrGameNotFound.With(map[string]interface{}{"game_id": gameID})
+	}
+
+	l := h.events.get(gameID)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.state == nil {
+		return game.ErrGameClosed
+	}
+	client.Send(MsgResyncState, &ResyncMessage{
+		Game:       l.state,
+		Seat:       game.SeatOf(l.state, client.ID()),
+		Spectators: h.spectators.count(gameID),
+		Seq:        l.seq,
+	})
+	return nil
+}
@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// newTestLog returns the log of a game between alice (p1) and a bot holding
+// events numbered 1 to n.
+func newTestLog(n int) *gameLog {
+	l := &gameLog{acked: make(map[string]int64)}
+	l.state = &models.Game{
+		ID:      "g1",
+		Player1: &models.Player{ID: "p1", Username: "alice"},
+		Player2: &models.Player{ID: "bot-1", Username: "Bot"},
+	}
+	for i := 0; i < n; i++ {
+		l.appendLocked(nil, MsgSpectatorCount, nil)
+	}
+	return l
+}
+
+func TestGameLogSince(t *testing.T) {
+	tests := []struct {
+		name   string
+		events int
+		// acked is how far alice has acked, if at all.
+		acked    int64
+		since    int64
+		wantSeqs []int64
+		wantOK   bool
+	}{
+		{"from the start", 5, 0, 0, []int64{1, 2, 3, 4, 5}, true},
+		{"part way", 5, 0, 3, []int64{4, 5}, true},
+		{"up to date", 5, 0, 5, nil, true},
+		{"ahead of the log", 5, 0, 7, nil, false},
+		{"acked events are let go", 5, 3, 2, nil, false},
+		{"after the acked events", 5, 3, 3, []int64{4, 5}, true},
+		{"acks past the end", 5, 9, 5, nil, true},
+		{"beyond the cap", maxReplayEvents + 10, 0, 5, nil, false},
+		{"within the cap", maxReplayEvents + 10, 0, maxReplayEvents + 8, []int64{maxReplayEvents + 9, maxReplayEvents + 10}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLog(tt.events)
+			if tt.acked > 0 {
+				l.ack("p1", tt.acked)
+			}
+
+			events, ok := l.sinceLocked(tt.since)
+			if ok != tt.wantOK {
+				t.Fatalf("sinceLocked(%d) ok = %v, want %v", tt.since, ok, tt.wantOK)
+			}
+			var seqs []int64
+			for _, event := range events {
+				seqs = append(seqs, event.seq)
+			}
+			if len(seqs) != len(tt.wantSeqs) || (len(seqs) > 0 && (seqs[0] != tt.wantSeqs[0] || seqs[len(seqs)-1] != tt.wantSeqs[len(tt.wantSeqs)-1])) {
+				t.Errorf("sinceLocked(%d) = %v, want %v", tt.since, seqs, tt.wantSeqs)
+			}
+			if len(l.events) > maxReplayEvents {
+				t.Errorf("log holds %d events, over the cap of %d", len(l.events), maxReplayEvents)
+			}
+		})
+	}
+}
+
+// Every player still in the game and every spectator gets each event with
+// its number; a player who has moved on gets nothing.
+func TestSendToGame(t *testing.T) {
+	h := newTestHandler(map[string]string{"p1": "g1", "p2": "g2"})
+	h.events = newGameLogs()
+	h.spectators = newSpectators(0)
+	snapshot := &models.Game{
+		ID:      "g1",
+		Player1: &models.Player{ID: "p1", Username: "p1"},
+		Player2: &models.Player{ID: "p2", Username: "p2"},
+	}
+
+	for i := 0; i < 2; i++ {
+		h.sendToGame("g1", snapshot, MsgSpectatorCount, &SpectatorCountMessage{GameID: "g1", Spectators: i})
+	}
+
+	p1, p2 := h.hub.GetClient("p1"), h.hub.GetClient("p2")
+	if len(p2.send) != 0 {
+		t.Errorf("the player who moved on was sent %d events", len(p2.send))
+	}
+	if len(p1.send) != 2 {
+		t.Fatalf("player sent %d events, want 2", len(p1.send))
+	}
+	for want := int64(1); want <= 2; want++ {
+		var msg struct {
+			Seq int64 `json:"seq"`
+		}
+		if err := json.Unmarshal(<-p1.send, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Seq != want {
+			t.Errorf("event seq = %d, want %d", msg.Seq, want)
+		}
+	}
+}
+
+// A client that cannot keep up is disconnected rather than quietly missing
+// a message.
+func TestQueueFull(t *testing.T) {
+	client := &Client{username: "alice", send: make(chan []byte, 1)}
+	if err := client.queue([]byte("1")); err != nil {
+		t.Fatalf("first message: %v", err)
+	}
+	if err := client.queue([]byte("2")); err != errClientClosed {
+		t.Errorf("message to a full buffer = %v, want errClientClosed", err)
+	}
+	if err := client.queue([]byte("3")); err != errClientClosed {
+		t.Errorf("message after closing = %v, want errClientClosed", err)
+	}
+}
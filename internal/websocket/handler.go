@@ -1,9 +1,9 @@
 package websocket
 
 import (
-    "encoding/json"
     "log"
     "net/http"
+    "strings"
     "time"
     "github.com/gorilla/websocket"
     "github.com/google/uuid"
@@ -16,28 +16,83 @@ import (
 )
 
 var upgrader = websocket.Upgrader{
+    // Offering a subprotocol is how clients ask for a protocol version
+    Subprotocols: []string{ProtocolV2Name, ProtocolV1Name},
     CheckOrigin: func(r *http.Request) bool {
         return true
     },
 }
 
+// HandlerConfig holds the handler's tunables.
+type HandlerConfig struct {
+    // MaxSpectators caps the spectators of a single game; 0 means no limit.
+    MaxSpectators int
+    // PublicURL is where the site is served, used to build share links. If
+    // empty, share links are relative.
+    PublicURL string
+    // ChatRateLimit is how many chat messages a client may send in any
+    // ChatRateWindow; 0 means no limit.
+    ChatRateLimit  int
+    ChatRateWindow time.Duration
+    // ChatFilter lists the words masked out of chat messages.
+    ChatFilter []string
+    // SpectatorChat lets spectators talk among themselves.
+    SpectatorChat bool
+    // EmoteCooldown is the least time between two emotes from a client.
+    EmoteCooldown time.Duration
+    // InviteTTL is how long an invite to a private game stays open.
+    InviteTTL time.Duration
+}
+
+// DefaultHandlerConfig returns the settings used when none are configured.
+func DefaultHandlerConfig() HandlerConfig {
+    return HandlerConfig{
+        MaxSpectators:  50,
+        ChatRateLimit:  5,
+        ChatRateWindow: 10 * time.Second,
+        SpectatorChat:  true,
+        EmoteCooldown:  3 * time.Second,
+        InviteTTL:      15 * time.Minute,
+    }
+}
+
 type Handler struct {
     hub         *Hub
     gameManager *game.Manager
     matchmaker  *matchmaking.Matchmaker
     db          *database.DB
     kafkaProducer *kafka.Producer
+    sessions    *SessionSigner
+    spectators  *spectators
+    lobby       *lobby
+    chatFilter  *wordFilter
+    rematches   *rematches
+    invites     *invites
+    events      *gameLogs
+    socials     *socialStates
+    config      HandlerConfig
 }
 
-func NewHandler(hub *Hub, gameManager *game.Manager, matchmaker *matchmaking.Matchmaker, 
-    db *database.DB, kafkaProducer *kafka.Producer) *Handler {
-    return &Handler{
+func NewHandler(config HandlerConfig, hub *Hub, gameManager *game.Manager, matchmaker *matchmaking.Matchmaker, 
+    db *database.DB, kafkaProducer *kafka.Producer, sessions *SessionSigner) *Handler {
+    h := &Handler{
         hub:         hub,
         gameManager: gameManager,
         matchmaker:  matchmaker,
         db:          db,
         kafkaProducer: kafkaProducer,
+        sessions:    sessions,
+        spectators:  newSpectators(config.MaxSpectators),
+        lobby:       newLobby(hub.Broadcast),
+        chatFilter:  newWordFilter(config.ChatFilter),
+        rematches:   newRematches(),
+        invites:     newInvites(config.InviteTTL),
+        events:      newGameLogs(),
+        socials:     newSocialStates(sessions.ttl),
+        config:      config,
     }
+    gameManager.OnUpdate(h.handleUpdate)
+    return h
 }
 
 func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -55,100 +110,286 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 
     playerID := uuid.New().String()
     client := NewClient(playerID, username, h.hub, conn)
+    client.compact = r.URL.Query().Get("compact") == "true"
     
     h.hub.register <- client
 
     // Save player to database
     h.db.CreateOrGetPlayer(username, playerID)
+    h.loadBlocked(client)
+
+    // Version 1 clients predate the greeting and are not sent one
+    if client.protocol == ProtocolV2 {
+        client.Send(MsgWelcome, &WelcomeMessage{
+            Version:  client.protocol,
+            Versions: supportedVersions,
+            PlayerID: playerID,
+        })
+    }
 
     go client.writePump()
-    go client.readPump(h.handleMessage)
+    go func() {
+        client.readPump(h.handleMessage)
+        h.stopSpectating(client)
+        h.invites.cancel(client)
+    }()
 }
 
 func (h *Handler) handleMessage(client *Client, message []byte) {
-    var msg map[string]interface{}
-    if err := json.Unmarshal(message, &msg); err != nil {
-        log.Println("Error parsing message:", err)
+    req, err := decodeRequest(client.protocol, message)
+    if err != nil {
+        log.Printf("Rejected message from %s: %v", client.username, err)
+        client.SendError(req.ID, err)
         return
     }
 
-    msgType, ok := msg["type"].(string)
-    if !ok {
-        return
+    if err := h.dispatch(client, req); err != nil {
+        client.SendError(req.ID, err)
     }
+}
 
-    switch msgType {
-    case "find_match":
-        h.handleFindMatch(client)
-    case "make_move":
-        h.handleMakeMove(client, msg)
-    case "rejoin":
-        h.handleRejoin(client, msg)
+// dispatch runs the handler for a decoded request. An error it returns
+// answers the request.
+func (h *Handler) dispatch(client *Client, req *request) error {
+    switch payload := req.Payload.(type) {
+    case *FindMatchRequest:
+        return h.handleFindMatch(client, payload)
+    case *MakeMoveRequest:
+        return h.handleMakeMove(client, payload)
+    case *StartPracticeRequest:
+        return h.handleStartPractice(client, payload)
+    case *ResignRequest:
+        return h.handleResign(client)
+    case *SwapRequest:
+        return h.handleSwap(client)
+    case *UsePowerUpRequest:
+        return h.handleUsePowerUp(client, payload)
+    case *TeamChatRequest:
+        return h.handleTeamChat(client, payload)
+    case *ChatRequest:
+        return h.handleChat(client, payload)
+    case *EmoteRequest:
+        return h.handleEmote(client, payload)
+    case *SetEmotesRequest:
+        return h.handleSetEmotes(client, payload)
+    case *MuteRequest:
+        return h.handleMute(client, payload.Username, true)
+    case *UnmuteRequest:
+        return h.handleMute(client, payload.Username, false)
+    case *BlockRequest:
+        return h.handleBlock(client, payload.Username, true)
+    case *UnblockRequest:
+        return h.handleBlock(client, payload.Username, false)
+    case *RequestTakebackRequest:
+        return h.handleRequestTakeback(client)
+    case *RespondTakebackRequest:
+        return h.handleRespondTakeback(client, payload)
+    case *RejoinRequest:
+        return h.handleRejoin(client, payload)
+    case *AckRequest:
+        return h.handleAck(client, payload)
+    case *ResyncRequest:
+        return h.handleResync(client)
+    case *SpectateRequest:
+        return h.handleSpectate(client, payload)
+    case *StopSpectatingRequest:
+        h.stopSpectating(client)
+        return nil
+    case *OfferRematchRequest:
+        return h.handleOfferRematch(client)
+    case *AcceptRematchRequest:
+        return h.handleAcceptRematch(client)
+    case *DeclineRematchRequest:
+        return h.handleDeclineRematch(client)
+    case *CreatePrivateRequest:
+        return h.handleCreatePrivate(client, payload)
+    case *JoinPrivateRequest:
+        return h.handleJoinPrivate(client, payload)
+    case *CancelPrivateRequest:
+        return h.handleCancelPrivate(client)
+    case *SubscribeLobbyRequest:
+        return h.handleSubscribeLobby(client)
+    case *UnsubscribeLobbyRequest:
+        return h.handleUnsubscribeLobby(client)
     }
+    return nil
 }
 
-func (h *Handler) handleFindMatch(client *Client) {
+func (h *Handler) handleFindMatch(client *Client, req *FindMatchRequest) error {
     player := &models.Player{
-        ID:       client.id,
+        ID:       client.ID(),
         Username: client.username,
         Piece:    1,
     }
 
-    gameChan := h.matchmaker.AddPlayer(player)
+    // Matchmaking only lets players pick the ruleset and the number of
+    // players; the rest of the settings belong to practice games
+    settings := models.GameSettings{
+        Ruleset: req.Ruleset,
+        Seats:   req.Players,
+    }
+    // The arcade queue is for fun and never rated
+    if settings.Ruleset == models.RulesetArcade {
+        settings.Mode = models.ModeCasual
+    }
+    if req.Mode == matchModeTeams {
+        settings.Seats = 4
+        settings.Teams = true
+    }
+    if _, _, _, err := game.StartingPosition(settings); err != nil {
+        return err
+    }
+
+    gameChan := h.matchmaker.AddParty(player, settings, req.Partner)
 
     // Try immediate match
-    gameInstance, opponent := h.matchmaker.TryMatch(player.ID)
+    gameInstance := h.matchmaker.TryMatch(player.ID)
     
     if gameInstance != nil {
-        // Found a match
-        h.startGame(client, gameInstance, opponent)
-        return
+        // Found a match; the opponents' waiting goroutines pick up the same
+        // game from their channels and join on their own
+        if err := h.startGame(gameInstance); err != nil {
+            return err
+        }
+        h.joinGame(client, gameInstance)
+        return nil
     }
 
     // Wait for match with timeout
     go func() {
+        if settings.Seats > 2 {
+            h.waitForGroup(client, player, gameChan)
+            return
+        }
+
         select {
-        case game := <-gameChan:
-            h.startGame(client, game, nil)
+        case game, ok := <-gameChan:
+            // A closed channel means the player left the queue, for a
+            // private game, a rematch or another search
+            if ok {
+                h.joinGame(client, game)
+            }
         case <-time.After(10 * time.Second):
-            // Timeout - start game with bot
-            h.startGameWithBot(client, player)
+            // Timeout - start game with bot, unless the player has been
+            // matched or has left the queue in the meantime
+            if !h.matchmaker.RemovePlayer(player.ID) {
+                if game, ok := <-gameChan; ok {
+                    h.joinGame(client, game)
+                }
+                return
+            }
+            if err := h.startGameWithBot(client, player, settings); err != nil {
+                client.SendError("", err)
+            }
         }
     }()
+    return nil
 }
 
-func (h *Handler) startGame(client *Client, gameInstance *game.GameInstance, opponent *models.Player) {
-    client.gameID = gameInstance.ID
-    h.gameManager.AddGame(gameInstance)
-
-    // Send game start to client
-    client.SendJSON(map[string]interface{}{
-        "type": "game_start",
-        "game": gameInstance.Game,
-    })
+// groupWaitTimeout is how long a player waits for a free-for-all group to
+// fill. There is no bot to fall back on for these games.
+const groupWaitTimeout = 2 * time.Minute
 
-    // Send to opponent if exists
-    if opponent != nil {
-        oppClient := h.hub.GetClient(opponent.ID)
-        if oppClient != nil {
-            oppClient.gameID = gameInstance.ID
-            oppClient.SendJSON(map[string]interface{}{
-                "type": "game_start",
-                "game": gameInstance.Game,
-            })
+func (h *Handler) waitForGroup(client *Client, player *models.Player, gameChan chan *game.GameInstance) {
+    select {
+    case game, ok := <-gameChan:
+        if ok {
+            h.joinGame(client, game)
+        }
+    case <-time.After(groupWaitTimeout):
+        // The group may have filled just as the wait ran out, or the player
+        // may have left the queue
+        if !h.matchmaker.RemovePlayer(player.ID) {
+            if game, ok := <-gameChan; ok {
+                h.joinGame(client, game)
+            }
+            return
         }
+        client.SendError("", &ProtocolError{
+            Code:    CodeGroupNotFilled,
+            Message: "not enough players for a group game, try again later",
+            Details: map[string]interface{}{"waited_seconds": int(groupWaitTimeout.Seconds())},
+        })
+    }
+}
+
+// handleStartPractice starts a bot game right away, optionally from a custom
+// position or with a handicap.
+func (h *Handler) handleStartPractice(client *Client, req *StartPracticeRequest) error {
+    settings := req.Settings
+    settings.Mode = models.ModePractice
+
+    player := &models.Player{
+        ID:       client.ID(),
+        Username: client.username,
+        Piece:    1,
+    }
+    return h.startGameWithBot(client, player, settings)
+}
+
+// startGame hands a freshly created game to the manager and records it. A
+// game the manager refuses is closed so nobody waits on it.
+func (h *Handler) startGame(gameInstance *game.GameInstance) error {
+    if err := h.gameManager.AddGame(gameInstance); err != nil {
+        gameInstance.Close()
+        return err
     }
+    snapshot := gameInstance.Snapshot()
+    if snapshot != nil {
+        h.events.open(snapshot, h.isLive)
+    }
+    h.lobbyAdd(snapshot)
 
     // Send analytics event
     h.kafkaProducer.SendGameEvent(&models.GameEvent{
         Type:      "game_start",
         GameID:    gameInstance.ID,
-        Data:      gameInstance.Game,
+        Data:      snapshot,
         Timestamp: time.Now(),
     })
+    return nil
 }
 
-func (h *Handler) startGameWithBot(client *Client, player *models.Player) {
+// joinGame attaches a client to a started game and tells it the game has
+// begun, along with the resume token it needs to reclaim the seat after a
+// reconnect and the players' head-to-head score.
+func (h *Handler) joinGame(client *Client, gameInstance *game.GameInstance) {
+    h.stopSpectating(client)
+    client.setGameID(gameInstance.ID)
+
+    snapshot := gameInstance.Snapshot()
+    if snapshot == nil {
+        client.SendError("", game.ErrTooManyGames)
+        return
+    }
+    headToHead := h.headToHead(snapshot)
+
+    // The game is sent as of its latest event, so the events that follow
+    // carry on from it
+    events := h.events.get(gameInstance.ID)
+    events.mu.Lock()
+    defer events.mu.Unlock()
+    if events.state != nil {
+        snapshot = events.state
+    }
+
+    seat := game.SeatOf(snapshot, client.ID())
+    token, err := h.sessions.Issue(gameInstance.ID, client.ID(), client.username, seat)
+    if err != nil {
+        log.Printf("Failed to issue resume token for %s: %v", client.username, err)
+    }
+
+    client.Send(MsgGameStart, &GameStartMessage{
+        Game:        snapshot,
+        Seat:        seat,
+        ResumeToken: token,
+        ShareLink:   shareLink(h.config.PublicURL, gameInstance.ID),
+        HeadToHead:  headToHead,
+        Seq:         events.seq,
+    })
+}
+
+func (h *Handler) startGameWithBot(client *Client, player *models.Player, settings models.GameSettings) error {
     h.matchmaker.RemovePlayer(player.ID)
 
     botPlayer := &models.Player{
@@ -157,178 +398,557 @@ func (h *Handler) startGameWithBot(client *Client, player *models.Player) {
         Piece:    2,
     }
 
-    gameInstance := game.NewGame(player, true)
+    gameInstance, err := game.NewGameWithSettings(player, true, settings)
+    if err != nil {
+        return err
+    }
     gameInstance.AddPlayer2(botPlayer)
-    
-    client.gameID = gameInstance.ID
-    h.gameManager.AddGame(gameInstance)
 
-    client.SendJSON(map[string]interface{}{
-        "type": "game_start",
-        "game": gameInstance.Game,
-    })
+    if err := h.startGame(gameInstance); err != nil {
+        return err
+    }
+    h.joinGame(client, gameInstance)
 
-    h.kafkaProducer.SendGameEvent(&models.GameEvent{
-        Type:      "game_start",
-        GameID:    gameInstance.ID,
-        Data:      gameInstance.Game,
-        Timestamp: time.Now(),
+    // The bot may be set to move first
+    if snapshot := gameInstance.Snapshot(); snapshot != nil && snapshot.CurrentTurn == botSeat(snapshot) {
+        go h.makeBotMove(gameInstance)
+    }
+    return nil
+}
+
+func (h *Handler) handleMakeMove(client *Client, req *MakeMoveRequest) error {
+    gameInstance, playerNum, err := h.playerGame(client)
+    if err != nil {
+        return err
+    }
+
+    return gameInstance.MakeMove(*req.Column, playerNum)
+}
+
+func (h *Handler) handleResign(client *Client) error {
+    gameInstance, playerNum, err := h.playerGame(client)
+    if err != nil {
+        return err
+    }
+
+    return gameInstance.Resign(playerNum)
+}
+
+func (h *Handler) handleUsePowerUp(client *Client, req *UsePowerUpRequest) error {
+    gameInstance, playerNum, err := h.playerGame(client)
+    if err != nil {
+        return err
+    }
+
+    return gameInstance.UsePowerUp(req.PowerUp, *req.Column, playerNum)
+}
+
+func (h *Handler) handleSwap(client *Client) error {
+    gameInstance, playerNum, err := h.playerGame(client)
+    if err != nil {
+        return err
+    }
+
+    return gameInstance.Swap(playerNum)
+}
+
+// handleTeamChat relays a message to the sender's team only. It goes
+// through the same limits, filter and log as other chat.
+func (h *Handler) handleTeamChat(client *Client, req *TeamChatRequest) error {
+    gameInstance, seat, err := h.playerGame(client)
+    if err != nil {
+        return err
+    }
+    snapshot := gameInstance.Snapshot()
+    if snapshot == nil || !snapshot.Settings.Teams {
+        return &ProtocolError{Code: CodeTeamChatOnly, Message: "team chat is only available in team games"}
+    }
+
+    entry, err := h.postChat(client, snapshot.ID, models.ChatTeam, seat, req.Text)
+    if err != nil {
+        return err
+    }
+    var mates []*Client
+    for _, mate := range game.Teammates(snapshot, game.TeamOf(seat)) {
+        if mateClient := h.hub.GetClient(mate.ID); mateClient != nil {
+            mates = append(mates, mateClient)
+        }
+    }
+    h.deliverChat(mates, entry.From, MsgTeamChat, &TeamChatMessage{
+        From: entry.From,
+        Seat: seat,
+        Text: entry.Text,
     })
+    return nil
 }
 
-func (h *Handler) handleMakeMove(client *Client, msg map[string]interface{}) {
-    gameID := client.gameID
-    col, ok := msg["column"].(float64)
-    if !ok {
-        return
+func (h *Handler) handleRequestTakeback(client *Client) error {
+    gameInstance, playerNum, err := h.playerGame(client)
+    if err != nil {
+        return err
+    }
+
+    return gameInstance.RequestTakeback(playerNum)
+}
+
+func (h *Handler) handleRespondTakeback(client *Client, req *RespondTakebackRequest) error {
+    gameInstance, playerNum, err := h.playerGame(client)
+    if err != nil {
+        return err
     }
 
+    return gameInstance.RespondTakeback(playerNum, req.Accept)
+}
+
+// playerGame looks up the game the client is seated in.
+func (h *Handler) playerGame(client *Client) (*game.GameInstance, int, error) {
+    gameID := client.GameID()
+    if gameID == "" {
+        return nil, 0, game.ErrNotInGame
+    }
     gameInstance, exists := h.gameManager.GetGame(gameID)
     if !exists {
-        return
+        return nil, 0, game.ErrGameNotFound.With(map[string]interface{}{"game_id": gameID})
     }
 
-    playerNum := 1
-    if client.id == gameInstance.Player2.ID {
-        playerNum = 2
+    playerNum := gameInstance.SeatOf(client.ID())
+    if playerNum == 0 {
+        return nil, 0, game.ErrNotInGame
     }
 
-    row, success, result := gameInstance.MakeMove(int(col), playerNum)
-    if !success {
-        client.SendJSON(map[string]interface{}{
-            "type":  "error",
-            "message": result,
-        })
-        return
+    return gameInstance, playerNum, nil
+}
+
+// handleUpdate receives every state change from the running games, in order
+// per game, and fans it out to the players and the lobby.
+func (h *Handler) handleUpdate(gameInstance *game.GameInstance, update game.Update) {
+    h.updateLobby(update)
+
+    switch update.Type {
+    case game.UpdateMove:
+        h.handleMoveMade(gameInstance, update)
+    case game.UpdateEnd:
+        h.handleGameEnd(update)
+    case game.UpdateTakebackRequested, game.UpdateTakebackDeclined, game.UpdateTakeback:
+        h.handleTakebackUpdate(update)
+    case game.UpdateSwap:
+        h.handleSwapUpdate(gameInstance, update)
+    case game.UpdateEliminated:
+        h.handleEliminated(update)
+    case game.UpdateRestored:
+        h.events.open(update.Game, h.isLive)
+        // Nobody else will wake the bot after a restart
+        snapshot := update.Game
+        if snapshot.IsBot && snapshot.Status == models.StatusPlaying && snapshot.CurrentTurn == botSeat(snapshot) {
+            go h.makeBotMove(gameInstance)
+        }
     }
+}
 
-    // Broadcast move to both players
-    moveData := map[string]interface{}{
-        "type":   "move_made",
-        "column": int(col),
-        "row":    row,
-        "player": playerNum,
-        "game":   gameInstance.Game,
+func (h *Handler) handleMoveMade(gameInstance *game.GameInstance, update game.Update) {
+    snapshot := update.Game
+
+    // A plain move changes one cell; power-ups list what they changed
+    changes := update.Move.Changes
+    if len(changes) == 0 {
+        changes = []models.CellChange{{Row: update.Move.Row, Column: update.Move.Column, Value: update.Move.Player}}
     }
 
-    client.SendJSON(moveData)
-    
-    // Send to opponent
-    opponentID := gameInstance.Player2.ID
-    if playerNum == 2 {
-        opponentID = gameInstance.Player1.ID
+    // Broadcast move to both players
+    msgType := MsgMoveMade
+    moveData := &MoveMessage{
+        Column:  update.Move.Column,
+        Row:     update.Move.Row,
+        Player:  update.Move.Player,
+        Changes: changes,
+        Game:    snapshot,
     }
-    
-    if opponentClient := h.hub.GetClient(opponentID); opponentClient != nil {
-        opponentClient.SendJSON(moveData)
+    if game.IsPowerUp(update.Move.Type) {
+        msgType = MsgPowerUpUsed
+        moveData.PowerUp = update.Move.Type
     }
+    h.sendToGame(snapshot.ID, snapshot, msgType, moveData)
 
     // Send analytics event
     h.kafkaProducer.SendGameEvent(&models.GameEvent{
-        Type:      "move_made",
-        GameID:    gameID,
+        Type:      msgType,
+        GameID:    snapshot.ID,
         Data:      moveData,
         Timestamp: time.Now(),
     })
 
-    // Handle game end
-    if result == "win" || result == "draw" {
-        h.handleGameEnd(gameInstance, result)
-    } else if gameInstance.IsBot && result == "continue" && gameInstance.CurrentTurn == 2 {
-        // Bot's turn if game continues and it's bot game
+    // Bot's turn if game continues and it's bot game
+    if snapshot.IsBot && update.Result == game.ResultContinue && snapshot.CurrentTurn == botSeat(snapshot) {
         time.AfterFunc(500*time.Millisecond, func() {
             h.makeBotMove(gameInstance)
         })
     }
 }
 
-func (h *Handler) makeBotMove(gameInstance *game.GameInstance) {
-    botAI := bot.NewBot(2)
-    col := botAI.GetMove(gameInstance.GetBoard())
-    
-    if col == -1 {
-        return
+func (h *Handler) handleTakebackUpdate(update game.Update) {
+    data := &TakebackMessage{
+        Seat: update.Seat,
+        Game: update.Game,
     }
-
-    row, success, result := gameInstance.MakeMove(col, 2)
-    if !success {
-        return
+    if update.Type == game.UpdateTakeback {
+        data.Undone = update.Undone
     }
+    h.sendToGame(update.Game.ID, update.Game, update.Type, data)
 
-    // Send bot move to player
-    moveData := map[string]interface{}{
-        "type":   "move_made",
-        "column": col,
-        "row":    row,
-        "player": 2,
-        "game":   gameInstance.Game,
-    }
+    h.kafkaProducer.SendGameEvent(&models.GameEvent{
+        Type:      update.Type,
+        GameID:    update.Game.ID,
+        Data:      data,
+        Timestamp: time.Now(),
+    })
+}
 
-    if playerClient := h.hub.GetClient(gameInstance.Player1.ID); playerClient != nil {
-        playerClient.SendJSON(moveData)
+// handleEliminated tells the players of a free-for-all game that one of them
+// is out and the game goes on without them.
+func (h *Handler) handleEliminated(update game.Update) {
+    data := &EliminatedMessage{
+        Seat:   update.Seat,
+        Reason: update.Result,
+        Game:   update.Game,
     }
+    h.sendToGame(update.Game.ID, update.Game, MsgPlayerEliminated, data)
 
     h.kafkaProducer.SendGameEvent(&models.GameEvent{
-        Type:      "move_made",
-        GameID:    gameInstance.ID,
-        Data:      moveData,
+        Type:      MsgPlayerEliminated,
+        GameID:    update.Game.ID,
+        Data:      data,
         Timestamp: time.Now(),
     })
+}
 
-    if result == "win" || result == "draw" {
-        h.handleGameEnd(gameInstance, result)
+// handleSwapUpdate tells both players their seats were exchanged. Each gets
+// its new seat so the client can flip its colour.
+func (h *Handler) handleSwapUpdate(gameInstance *game.GameInstance, update game.Update) {
+    snapshot := update.Game
+
+    h.sendToGame(snapshot.ID, snapshot, MsgSwap, &SwapMessage{Game: snapshot})
+
+    h.kafkaProducer.SendGameEvent(&models.GameEvent{
+        Type:      "swap",
+        GameID:    snapshot.ID,
+        Data:      snapshot,
+        Timestamp: time.Now(),
+    })
+
+    // The player who was swapped out now moves as seat 2
+    if snapshot.IsBot && snapshot.CurrentTurn == botSeat(snapshot) {
+        time.AfterFunc(500*time.Millisecond, func() {
+            h.makeBotMove(gameInstance)
+        })
     }
 }
 
-func (h *Handler) handleGameEnd(gameInstance *game.GameInstance, result string) {
-    // Save to database
-    h.db.SaveGame(gameInstance.Game)
-    h.db.UpdateGameStats(gameInstance.Game)
+func (h *Handler) makeBotMove(gameInstance *game.GameInstance) {
+    snapshot := gameInstance.Snapshot()
+    board := gameInstance.BoardSnapshot()
+    if snapshot == nil || board == nil {
+        return
+    }
 
-    // Send game end event
-    endData := map[string]interface{}{
-        "type":   "game_end",
-        "result": result,
-        "winner": gameInstance.Winner,
-        "game":   gameInstance.Game,
+    seat := botSeat(snapshot)
+    botAI := bot.NewBotWithLevel(seat, snapshot.Settings.BotLevel)
+
+    if game.CanSwap(snapshot) && botAI.ShouldSwap(board) {
+        if err := gameInstance.Swap(seat); err != nil {
+            log.Printf("Bot swap rejected in game %s: %v", gameInstance.ID, err)
+        }
+        return
     }
 
-    if player1Client := h.hub.GetClient(gameInstance.Player1.ID); player1Client != nil {
-        player1Client.SendJSON(endData)
+    col := botAI.GetMove(board)
+    
+    if col == -1 {
+        return
     }
 
-    if gameInstance.Player2 != nil {
-        if player2Client := h.hub.GetClient(gameInstance.Player2.ID); player2Client != nil {
-            player2Client.SendJSON(endData)
-        }
+    if err := gameInstance.MakeMove(col, seat); err != nil {
+        log.Printf("Bot move rejected in game %s: %v", gameInstance.ID, err)
     }
+}
+
+func (h *Handler) handleGameEnd(update game.Update) {
+    snapshot := update.Game
+
+    // Save to database; only rated games that actually started count in
+    // stats
+    h.db.SaveGame(snapshot)
+    if update.Result != game.ResultAbandoned && isRated(snapshot) {
+        h.db.UpdateGameStats(snapshot)
+    }
+
+    // Send game end event
+    endData := &GameEndMessage{
+        Result: update.Result,
+        Winner: snapshot.Winner,
+        Game:   snapshot,
+    }
+    h.sendToGame(snapshot.ID, snapshot, MsgGameEnd, endData)
+    h.spectators.drop(snapshot.ID)
 
     h.kafkaProducer.SendGameEvent(&models.GameEvent{
         Type:      "game_end",
-        GameID:    gameInstance.ID,
+        GameID:    snapshot.ID,
         Data:      endData,
         Timestamp: time.Now(),
     })
+
+    if snapshot.Settings.MatchID != "" && update.Result != game.ResultAbandoned {
+        h.continueMatch(snapshot)
+    }
 }
 
-func (h *Handler) handleRejoin(client *Client, msg map[string]interface{}) {
-    gameID, ok := msg["game_id"].(string)
-    if !ok {
+// matchBreak is the pause between the two games of a mini-match, so players
+// see how the first one ended.
+const matchBreak = 3 * time.Second
+
+// continueMatch follows the first game of a balanced mini-match with the
+// return game, or reports the match score after the second.
+func (h *Handler) continueMatch(snapshot *models.Game) {
+    if snapshot.Settings.MatchGame == 2 {
+        records, err := h.db.GetMatchRecords(snapshot.Settings.MatchID)
+        if err != nil {
+            log.Printf("Failed to load match %s: %v", snapshot.Settings.MatchID, err)
+            return
+        }
+        h.sendToPlayers(snapshot, MsgMatchEnd, &MatchEndMessage{
+            MatchID: snapshot.Settings.MatchID,
+            Opening: snapshot.Settings.Opening,
+            Scores:  game.MatchScores(records),
+        })
         return
     }
 
-    gameInstance, exists := h.gameManager.GetGame(gameID)
+    time.AfterFunc(matchBreak, func() {
+        // Both human players have to still be around and not have moved on
+        // to another game
+        var clients []*Client
+        for _, player := range []*models.Player{snapshot.Player1, snapshot.Player2} {
+            if strings.HasPrefix(player.ID, "bot-") {
+                continue
+            }
+            playerClient := h.hub.GetClient(player.ID)
+            if playerClient == nil || playerClient.GameID() != snapshot.ID {
+                return
+            }
+            clients = append(clients, playerClient)
+        }
+
+        next, err := game.NextMatchGame(snapshot)
+        if err != nil || next == nil {
+            log.Printf("Failed to create return game for match %s: %v", snapshot.Settings.MatchID, err)
+            return
+        }
+        if err := h.startGame(next); err != nil {
+            for _, playerClient := range clients {
+                playerClient.SendError("", err)
+            }
+            return
+        }
+        for _, playerClient := range clients {
+            h.joinGame(playerClient, next)
+        }
+
+        if nextSnapshot := next.Snapshot(); nextSnapshot != nil && nextSnapshot.IsBot && nextSnapshot.CurrentTurn == botSeat(nextSnapshot) {
+            go h.makeBotMove(next)
+        }
+    })
+}
+
+// handleSpectate lets a client watch a live game. The game is read-only to
+// spectators: they are not seated, so every move they try is refused.
+func (h *Handler) handleSpectate(client *Client, req *SpectateRequest) error {
+    gameInstance, exists := h.gameManager.GetGame(req.GameID)
     if !exists {
-        client.SendJSON(map[string]interface{}{
-            "type":    "error",
-            "message": "Game not found",
+        return game.ErrGameNotFound.With(map[string]interface{}{"game_id": req.GameID})
+    }
+    snapshot := gameInstance.Snapshot()
+    if snapshot == nil {
+        return game.ErrGameClosed
+    }
+    if snapshot.Status != models.StatusPlaying {
+        return game.ErrGameNotPlaying.With(map[string]interface{}{"status": snapshot.Status})
+    }
+
+    // Joining and the first message happen in one step with the game's
+    // events, so the spectator gets every event after the one it starts at
+    events := h.events.get(snapshot.ID)
+    events.mu.Lock()
+    count, previous, err := h.spectators.add(snapshot.ID, client)
+    if err == nil {
+        if events.state != nil {
+            snapshot = events.state
+        }
+        client.Send(MsgSpectateStart, &SpectateStartMessage{
+            Game:       snapshot,
+            Spectators: count,
+            ShareLink:  shareLink(h.config.PublicURL, snapshot.ID),
+            Seq:        events.seq,
         })
-        return
     }
+    events.mu.Unlock()
+    if err != nil {
+        return err
+    }
+
+    if previous != "" {
+        h.announceSpectators(previous)
+    }
+    h.announceSpectators(snapshot.ID)
+    return nil
+}
+
+// stopSpectating takes the client off the game it is watching, if any.
+func (h *Handler) stopSpectating(client *Client) {
+    if gameID := h.spectators.leave(client); gameID != "" {
+        h.announceSpectators(gameID)
+    }
+}
 
-    client.gameID = gameID
-    client.SendJSON(map[string]interface{}{
-        "type": "rejoin_success",
-        "game": gameInstance.Game,
+// announceSpectators tells the players and spectators of a game how many
+// are watching.
+func (h *Handler) announceSpectators(gameID string) {
+    gameInstance, exists := h.gameManager.GetGame(gameID)
+    if !exists {
+        return
+    }
+    snapshot := gameInstance.Snapshot()
+    if snapshot == nil {
+        return
+    }
+    h.sendToGame(gameID, nil, MsgSpectatorCount, &SpectatorCountMessage{
+        GameID:     gameID,
+        Spectators: h.spectators.count(gameID),
     })
-}
\ No newline at end of file
+    h.lobbyUpdate(snapshot)
+}
+
+// connectedPlayers returns the clients of the game's players that are still
+// in it. A player who has moved on to another game is left out.
+func (h *Handler) connectedPlayers(snapshot *models.Game) []*Client {
+    var clients []*Client
+    for _, player := range game.Players(snapshot) {
+        if playerClient := h.hub.GetClient(player.ID); playerClient != nil && playerClient.GameID() == snapshot.ID {
+            clients = append(clients, playerClient)
+        }
+    }
+    return clients
+}
+
+// sendToPlayers delivers a message to every connected player of the game.
+func (h *Handler) sendToPlayers(snapshot *models.Game, msgType string, payload interface{}) {
+    for _, player := range game.Players(snapshot) {
+        if playerClient := h.hub.GetClient(player.ID); playerClient != nil {
+            playerClient.Send(msgType, payload)
+        }
+    }
+}
+
+func (h *Handler) handleRejoin(client *Client, req *RejoinRequest) error {
+    claims, err := h.sessions.Verify(req.ResumeToken)
+    if err != nil {
+        return err
+    }
+
+    // Older clients also send the game ID; it has to agree with the token
+    if req.GameID != "" && req.GameID != claims.GameID {
+        return &ProtocolError{Code: CodeTokenMismatch, Message: "resume token does not match game"}
+    }
+
+    if claims.Username != client.username {
+        return &ProtocolError{Code: CodeTokenMismatch, Message: "resume token belongs to another player"}
+    }
+
+    gameInstance, exists := h.gameManager.GetGame(claims.GameID)
+    if !exists {
+        return game.ErrGameNotFound.With(map[string]interface{}{"game_id": claims.GameID})
+    }
+
+    // The seat in the token may be stale after a swap; being a player in
+    // the game is what counts
+    state, err := gameInstance.Rejoin(claims.PlayerID, req.LastMove)
+    if err != nil {
+        return err
+    }
+    h.matchmaker.RemovePlayer(client.ID())
+
+    // The seat is taken over in one step with the game's events, so nothing
+    // sent in between is lost or arrives out of order
+    events := h.events.get(claims.GameID)
+    events.mu.Lock()
+    defer events.mu.Unlock()
+
+    // Take over the original identity so moves match the seat again
+    h.hub.Rebind(client, claims.PlayerID)
+    client.setGameID(claims.GameID)
+    h.loadBlocked(client)
+
+    msg := &RejoinMessage{
+        Seat:        state.Seat,
+        ResumeToken: req.ResumeToken,
+        MissedMoves: state.Missed,
+        Spectators:  h.spectators.count(claims.GameID),
+        Seq:         events.seq,
+    }
+    // Clients that give no last event get the whole game, as they always have
+    var replay []gameEvent
+    ok := false
+    if req.LastSeq > 0 {
+        replay, ok = events.sinceLocked(req.LastSeq)
+    }
+    if ok {
+        msg.Seq = req.LastSeq
+        msg.Replay = len(replay)
+    } else {
+        msg.Game = state.Game
+        if events.state != nil {
+            msg.Game = events.state
+        }
+        msg.Resync = req.LastSeq > 0
+    }
+
+    client.Send(MsgRejoinSuccess, msg)
+    for _, event := range replay {
+        client.SendEvent(event.seq, event.msgType, eventPayload(event, client))
+    }
+    return nil
+}
+
+// isLive reports whether the manager still holds a game.
+func (h *Handler) isLive(gameID string) bool {
+    _, exists := h.gameManager.GetGame(gameID)
+    return exists
+}
+
+// isPlaying reports whether a client is in a game that is still being
+// played.
+func (h *Handler) isPlaying(client *Client) bool {
+    return h.isPlayingGame(client.GameID())
+}
+
+// isPlayingGame reports whether a game is still being played.
+func (h *Handler) isPlayingGame(gameID string) bool {
+    gameInstance, exists := h.gameManager.GetGame(gameID)
+    if !exists {
+        return false
+    }
+    snapshot := gameInstance.Snapshot()
+    return snapshot != nil && snapshot.Status == models.StatusPlaying
+}
+
+// botSeat returns the seat the bot plays in a bot game. It starts as seat 2
+// but takes seat 1 if it swaps.
+func botSeat(snapshot *models.Game) int {
+    if snapshot.Player1 != nil && strings.HasPrefix(snapshot.Player1.ID, "bot-") {
+        return 1
+    }
+    return 2
+}
+
+func isRated(snapshot *models.Game) bool {
+    return snapshot.Settings.Mode == "" || snapshot.Settings.Mode == models.ModeRated
+}
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// newTestHandler returns a handler whose hub holds a client for each
+// player, in the game given by games[playerID].
+func newTestHandler(games map[string]string) *Handler {
+	hub := NewHub()
+	for playerID, gameID := range games {
+		client := &Client{id: playerID, username: playerID, hub: hub, send: make(chan []byte, 16), gameID: gameID}
+		hub.clients[playerID] = client
+	}
+	return &Handler{hub: hub}
+}
+
+func TestConnectedPlayers(t *testing.T) {
+	snapshot := &models.Game{
+		ID:      "g1",
+		Player1: &models.Player{ID: "p1", Username: "alice"},
+		Player2: &models.Player{ID: "p2", Username: "bob"},
+	}
+	tests := []struct {
+		name  string
+		games map[string]string
+		want  []string
+	}{
+		{"both in the game", map[string]string{"p1": "g1", "p2": "g1"}, []string{"p1", "p2"}},
+		{"one moved on", map[string]string{"p1": "g1", "p2": "g2"}, []string{"p1"}},
+		{"one disconnected", map[string]string{"p2": "g1"}, []string{"p2"}},
+		{"none in the game", map[string]string{"p1": "", "p2": "g3"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, client := range newTestHandler(tt.games).connectedPlayers(snapshot) {
+				got = append(got, client.ID())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("connectedPlayers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
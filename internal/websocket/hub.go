@@ -1,12 +1,23 @@
 package websocket
 
 import (
+    "log"
     "sync"
 )
 
+// broadcastMessage is a message for every lobby subscriber. It is encoded
+// once per protocol version in use.
+type broadcastMessage struct {
+    msgType string
+    payload interface{}
+}
+
 type Hub struct {
     clients    map[string]*Client
-    broadcast  chan []byte
+    // lobby holds the clients subscribed to the lobby feed, which is what
+    // broadcasts go to.
+    lobby      map[*Client]bool
+    broadcast  chan broadcastMessage
     register   chan *Client
     unregister chan *Client
     mu         sync.RWMutex
@@ -15,7 +26,8 @@ type Hub struct {
 func NewHub() *Hub {
     return &Hub{
         clients:    make(map[string]*Client),
-        broadcast:  make(chan []byte, 256),
+        lobby:      make(map[*Client]bool),
+        broadcast:  make(chan broadcastMessage, 256),
         register:   make(chan *Client),
         unregister: make(chan *Client),
     }
@@ -26,47 +38,107 @@ func (h *Hub) Run() {
         select {
         case client := <-h.register:
             h.mu.Lock()
-            h.clients[client.id] = client
+            h.clients[client.ID()] = client
             h.mu.Unlock()
 
         case client := <-h.unregister:
             h.mu.Lock()
-            if _, ok := h.clients[client.id]; ok {
-                delete(h.clients, client.id)
-                close(client.send)
+            delete(h.lobby, client)
+            if current, ok := h.clients[client.ID()]; ok && current == client {
+                delete(h.clients, client.ID())
             }
             h.mu.Unlock()
+            // A connection replaced by Rebind is no longer listed but still
+            // has its write pump running
+            client.closeSend()
 
         case message := <-h.broadcast:
-            h.mu.RLock()
-            for _, client := range h.clients {
-                select {
-                case client.send <- message:
-                default:
-                    close(client.send)
-                    delete(h.clients, client.id)
-                }
+            h.deliver(message)
+        }
+    }
+}
+
+// deliver sends a broadcast to the lobby subscribers. A subscriber too slow
+// to keep up is disconnected rather than holding up everyone else, and gets
+// the lobby afresh when it subscribes again.
+func (h *Hub) deliver(message broadcastMessage) {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+
+    encoded := make(map[int][]byte)
+    for client := range h.lobby {
+        data, ok := encoded[client.protocol]
+        if !ok {
+            var err error
+            data, err = encodeMessage(client.protocol, message.msgType, "", 0, message.payload)
+            if err != nil {
+                log.Printf("Failed to encode %s broadcast: %v", message.msgType, err)
+                return
             }
-            h.mu.RUnlock()
+            encoded[client.protocol] = data
         }
+
+        client.queue(data)
+    }
+}
+
+// Broadcast queues a message for every lobby subscriber.
+func (h *Hub) Broadcast(msgType string, payload interface{}) {
+    h.broadcast <- broadcastMessage{msgType: msgType, payload: payload}
+}
+
+// SetLobby subscribes the client to the lobby feed or unsubscribes it.
+func (h *Hub) SetLobby(client *Client, subscribed bool) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    if !subscribed {
+        delete(h.lobby, client)
+        return
+    }
+    // A client that has already disconnected must not be sent to
+    if current, ok := h.clients[client.ID()]; ok && current == client {
+        h.lobby[client] = true
     }
 }
 
+// Count returns the number of connected clients.
+func (h *Hub) Count() int {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return len(h.clients)
+}
+
 func (h *Hub) GetClient(id string) *Client {
     h.mu.RLock()
     defer h.mu.RUnlock()
     return h.clients[id]
 }
 
+// Rebind moves a client to a new id, typically the player ID restored from a
+// resume token. A stale connection still holding that id is closed.
+func (h *Hub) Rebind(client *Client, id string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    if current, ok := h.clients[client.ID()]; ok && current == client {
+        delete(h.clients, client.ID())
+    }
+
+    if stale, ok := h.clients[id]; ok && stale != client {
+        stale.conn.Close()
+    }
+
+    client.setID(id)
+    h.clients[id] = client
+}
+
 func (h *Hub) SendToClient(clientID string, message []byte) {
     h.mu.RLock()
     client, ok := h.clients[clientID]
     h.mu.RUnlock()
     
     if ok {
-        select {
-        case client.send <- message:
-        default:
-        }
+        client.queue(message)
     }
 }
\ No newline at end of file
@@ -0,0 +1,236 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// A private game starts from an invite: its creator gets a short code and a
+// link to pass on, and the friend who joins with it plays them directly,
+// with no matchmaking and no bot. Codes are single-use and expire, and carry
+// the settings of the game they start.
+
+// Who moves first in a private game.
+const (
+	firstMoverCreator = "creator"
+	firstMoverJoiner  = "joiner"
+	firstMoverRandom  = "random"
+)
+
+// inviteCodeAlphabet leaves out letters and digits that are easily confused.
+const inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+const inviteCodeLength = 6
+
+// inviteLinkPath is the path of an invite link, followed by the code.
+const inviteLinkPath = "/join/"
+
+type invite struct {
+	code       string
+	creator    *Client
+	settings   models.GameSettings
+	firstMover string
+	expires    time.Time
+}
+
+// invites holds the open invites by code. A client has at most one open
+// invite; creating another replaces it.
+type invites struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	codes map[string]*invite
+}
+
+func newInvites(ttl time.Duration) *invites {
+	return &invites{ttl: ttl, codes: make(map[string]*invite)}
+}
+
+// create opens an invite for the client.
+func (r *invites) create(creator *Client, settings models.GameSettings, firstMover string) (*invite, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for code, open := range r.codes {
+		if open.creator == creator || now.After(open.expires) {
+			delete(r.codes, code)
+		}
+	}
+
+	code, err := r.newCodeLocked()
+	if err != nil {
+		return nil, err
+	}
+	inv := &invite{
+		code:       code,
+		creator:    creator,
+		settings:   settings,
+		firstMover: firstMover,
+		expires:    now.Add(r.ttl),
+	}
+	r.codes[code] = inv
+	return inv, nil
+}
+
+func (r *invites) newCodeLocked() (string, error) {
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for {
+		var sb strings.Builder
+		for i := 0; i < inviteCodeLength; i++ {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteByte(inviteCodeAlphabet[n.Int64()])
+		}
+		if _, taken := r.codes[sb.String()]; !taken {
+			return sb.String(), nil
+		}
+	}
+}
+
+// redeem uses up an invite. The invite is gone afterwards whether or not it
+// could be used.
+func (r *invites) redeem(code string, joiner *Client) (*invite, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, ok := r.codes[code]
+	if !ok {
+		return nil, &ProtocolError{Code: CodeInviteNotFound, Message: "no such invite, or it has already been used"}
+	}
+	if inv.creator == joiner {
+		return nil, &ProtocolError{Code: CodeInviteOwn, Message: "you cannot join your own invite"}
+	}
+	delete(r.codes, code)
+
+	if time.Now().After(inv.expires) {
+		return nil, &ProtocolError{
+			Code:    CodeInviteExpired,
+			Message: "this invite has expired",
+			Details: map[string]interface{}{"expired_at": inv.expires},
+		}
+	}
+	return inv, nil
+}
+
+// cancel withdraws the client's open invite and reports whether it had one.
+func (r *invites) cancel(creator *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for code, open := range r.codes {
+		if open.creator == creator {
+			delete(r.codes, code)
+			return true
+		}
+	}
+	return false
+}
+
+// inviteLink returns the link that joins a private game.
+func inviteLink(publicURL, code string) string {
+	return strings.TrimRight(publicURL, "/") + inviteLinkPath + code
+}
+
+// parseInviteCode accepts a code as typed, in any case, or a whole invite
+// link.
+func parseInviteCode(s string) string {
+	s = strings.TrimSpace(s)
+	if _, code, ok := strings.Cut(s, inviteLinkPath); ok {
+		s = strings.Trim(code, "/")
+	}
+	return strings.ToUpper(s)
+}
+
+func (h *Handler) handleCreatePrivate(client *Client, req *CreatePrivateRequest) error {
+	settings := models.GameSettings{
+		Ruleset:     req.Ruleset,
+		Mode:        req.Mode,
+		TurnSeconds: req.TurnSeconds,
+		Private:     true,
+	}
+	// Arcade games are never rated, private or not
+	if settings.Ruleset == models.RulesetArcade {
+		settings.Mode = models.ModeCasual
+	}
+	if _, _, _, err := game.StartingPosition(settings); err != nil {
+		return err
+	}
+
+	inv, err := h.invites.create(client, settings, req.FirstMover)
+	if err != nil {
+		return err
+	}
+	client.Send(MsgPrivateCreated, &PrivateCreatedMessage{
+		Code:      inv.code,
+		Link:      inviteLink(h.config.PublicURL, inv.code),
+		ExpiresAt: inv.expires,
+		Settings:  inv.settings,
+	})
+	return nil
+}
+
+func (h *Handler) handleCancelPrivate(client *Client) error {
+	if !h.invites.cancel(client) {
+		return &ProtocolError{Code: CodeInviteNotFound, Message: "you have no open invite"}
+	}
+	return nil
+}
+
+// handleJoinPrivate starts the private game of an invite between its
+// creator and the client.
+func (h *Handler) handleJoinPrivate(client *Client, req *JoinPrivateRequest) error {
+	if h.isPlaying(client) {
+		return &ProtocolError{Code: CodeAlreadyPlaying, Message: "finish your current game before joining another"}
+	}
+	inv, err := h.invites.redeem(req.Code, client)
+	if err != nil {
+		return err
+	}
+
+	// The creator may have gone offline, or be playing by now
+	creator := inv.creator
+	if h.hub.GetClient(creator.ID()) != creator {
+		return &ProtocolError{Code: CodeInviteNotFound, Message: "the player who sent this invite is no longer online"}
+	}
+	if h.isPlaying(creator) {
+		return &ProtocolError{Code: CodeInviteNotFound, Message: "the player who sent this invite is in another game"}
+	}
+
+	// Neither player waits on matchmaking any more
+	h.matchmaker.RemovePlayer(creator.ID())
+	h.matchmaker.RemovePlayer(client.ID())
+
+	creatorPlayer := &models.Player{ID: creator.ID(), Username: creator.username}
+	joinerPlayer := &models.Player{ID: client.ID(), Username: client.username}
+	first, second := creatorPlayer, joinerPlayer
+	switch inv.firstMover {
+	case firstMoverJoiner:
+		first, second = joinerPlayer, creatorPlayer
+	case firstMoverRandom:
+		if n, err := rand.Int(rand.Reader, big.NewInt(2)); err == nil && n.Int64() == 1 {
+			first, second = joinerPlayer, creatorPlayer
+		}
+	}
+	first.Piece = 1
+	second.Piece = 2
+
+	gameInstance, err := game.NewGameWithSettings(first, false, inv.settings)
+	if err != nil {
+		return err
+	}
+	gameInstance.AddPlayer2(second)
+	if err := h.startGame(gameInstance); err != nil {
+		return err
+	}
+	h.joinGame(creator, gameInstance)
+	h.joinGame(client, gameInstance)
+	return nil
+}
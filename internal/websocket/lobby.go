@@ -0,0 +1,264 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// The lobby is the home screen's view of the server: the games being played,
+// how many players are online and how many are queued. A subscriber is sent
+// the whole lobby once, then an event over the hub's broadcast channel for
+// every game that starts, changes or ends.
+
+// lobbyCountsInterval is how often the online and queue counts are checked
+// and, if they changed, sent to subscribers.
+const lobbyCountsInterval = 2 * time.Second
+
+// lobbyEndedTTL is how long the lobby remembers that a game ended. Updates
+// for a game are only in flight for a moment after its end, so this is long
+// enough that a late one never lists it again.
+const lobbyEndedTTL = 10 * time.Minute
+
+// lobby holds the live games as subscribers see them. Entries are replaced,
+// never changed in place, since a broadcast may still be encoding the old
+// one. Every change is broadcast while the lock is held, so subscribers see
+// the changes in the order they were made.
+type lobby struct {
+	mu    sync.Mutex
+	games map[string]*LobbyGame
+	// ended holds the games that have left the lobby by ending, and when,
+	// so that an update racing with the end cannot list them again.
+	ended map[string]time.Time
+	// counts is what subscribers were last told.
+	counts LobbyCountsMessage
+	// broadcast sends an event to the lobby subscribers.
+	broadcast func(msgType string, payload interface{})
+}
+
+func newLobby(broadcast func(msgType string, payload interface{})) *lobby {
+	return &lobby{
+		games:     make(map[string]*LobbyGame),
+		ended:     make(map[string]time.Time),
+		broadcast: broadcast,
+	}
+}
+
+// list returns the live games, oldest first.
+func (l *lobby) list() []*LobbyGame {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	games := make([]*LobbyGame, 0, len(l.games))
+	for _, entry := range l.games {
+		games = append(games, entry)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].StartedAt.Before(games[j].StartedAt)
+	})
+	return games
+}
+
+// add lists a game that has started, unless it has already ended.
+func (l *lobby) add(entry *LobbyGame) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ended := l.ended[entry.ID]; ended {
+		return
+	}
+	l.games[entry.ID] = entry
+	l.broadcast(MsgLobbyGameAdded, &LobbyGameMessage{Game: entry})
+}
+
+// update replaces a listed game with the entry build makes from the current
+// one. Games that are not listed, including every game that has ended, are
+// left alone.
+func (l *lobby) update(gameID string, build func(current *LobbyGame) *LobbyGame) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current, ok := l.games[gameID]
+	if !ok {
+		return
+	}
+	entry := build(current)
+	l.games[gameID] = entry
+	l.broadcast(MsgLobbyGameUpdated, &LobbyGameMessage{Game: entry})
+}
+
+// end drops a game that has ended and keeps it from being listed again.
+func (l *lobby) end(gameID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ended[gameID] = time.Now()
+	if _, ok := l.games[gameID]; ok {
+		delete(l.games, gameID)
+		l.broadcast(MsgLobbyGameRemoved, &LobbyGameRemovedMessage{GameID: gameID})
+	}
+}
+
+// forgetEnded drops the ended games older than lobbyEndedTTL.
+func (l *lobby) forgetEnded(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for gameID, at := range l.ended {
+		if now.Sub(at) > lobbyEndedTTL {
+			delete(l.ended, gameID)
+		}
+	}
+}
+
+// setCounts records new counts and reports whether they changed.
+func (l *lobby) setCounts(counts LobbyCountsMessage) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if counts == l.counts {
+		return false
+	}
+	l.counts = counts
+	return true
+}
+
+// lobbyGame describes a game for the lobby. Player records are taken from
+// records, keyed by username.
+func lobbyGame(snapshot *models.Game, records map[string]LobbyPlayer, spectators int) *LobbyGame {
+	variant := snapshot.Settings.Ruleset
+	if variant == "" {
+		variant = models.RulesetStandard
+	}
+
+	entry := &LobbyGame{
+		ID:         snapshot.ID,
+		Moves:      game.MoveCount(snapshot),
+		Variant:    variant,
+		Seats:      game.SeatCount(snapshot),
+		Teams:      snapshot.Settings.Teams,
+		Mode:       snapshot.Settings.Mode,
+		IsBot:      snapshot.IsBot,
+		Spectators: spectators,
+		StartedAt:  snapshot.CreatedAt,
+	}
+	for i, player := range game.Players(snapshot) {
+		lobbyPlayer := records[player.Username]
+		lobbyPlayer.Username = player.Username
+		lobbyPlayer.Seat = i + 1
+		entry.Players = append(entry.Players, lobbyPlayer)
+	}
+	return entry
+}
+
+// handleSubscribeLobby starts the lobby feed for the client with the lobby as
+// it stands. Games that start while the snapshot is on its way may arrive as
+// events too, so clients treat an added game they already have as an update.
+func (h *Handler) handleSubscribeLobby(client *Client) error {
+	h.hub.SetLobby(client, true)
+	client.Send(MsgLobbySnapshot, &LobbySnapshotMessage{
+		Games:  h.lobby.list(),
+		Online: h.hub.Count(),
+		Queued: h.matchmaker.QueueLength(),
+	})
+	return nil
+}
+
+func (h *Handler) handleUnsubscribeLobby(client *Client) error {
+	h.hub.SetLobby(client, false)
+	return nil
+}
+
+// updateLobby keeps the lobby in step with a game's updates.
+func (h *Handler) updateLobby(update game.Update) {
+	switch update.Type {
+	case game.UpdateRestored:
+		h.lobbyAdd(update.Game)
+	case game.UpdateMove, game.UpdateTakeback, game.UpdateSwap, game.UpdateEliminated:
+		h.lobbyUpdate(update.Game)
+	case game.UpdateEnd:
+		h.lobby.end(update.Game.ID)
+	}
+}
+
+// lobbyAdd lists a game that has started, unless it is private. The players'
+// records are looked up once here and carried along with the game's later
+// updates.
+func (h *Handler) lobbyAdd(snapshot *models.Game) {
+	if snapshot == nil || snapshot.Status != models.StatusPlaying || snapshot.Settings.Private {
+		return
+	}
+
+	records := make(map[string]LobbyPlayer)
+	var usernames []string
+	for _, player := range game.Players(snapshot) {
+		if !strings.HasPrefix(player.ID, "bot-") {
+			usernames = append(usernames, player.Username)
+		}
+	}
+	if len(usernames) > 0 {
+		stats, err := h.db.GetPlayerStats(usernames)
+		if err != nil {
+			log.Printf("Failed to load player records for game %s: %v", snapshot.ID, err)
+		}
+		for _, entry := range stats {
+			records[entry.Username] = LobbyPlayer{Wins: entry.Wins, Losses: entry.Losses, Draws: entry.Draws}
+		}
+	}
+
+	h.lobby.add(lobbyGame(snapshot, records, h.spectators.count(snapshot.ID)))
+
+	// The game may have ended while it was being listed, before there was an
+	// entry for its end to remove
+	if !h.isPlayingGame(snapshot.ID) {
+		h.lobby.end(snapshot.ID)
+	}
+}
+
+// lobbyUpdate relists a game after a change. Games the lobby does not hold
+// are left alone.
+func (h *Handler) lobbyUpdate(snapshot *models.Game) {
+	if snapshot.Status != models.StatusPlaying {
+		return
+	}
+
+	spectators := h.spectators.count(snapshot.ID)
+	h.lobby.update(snapshot.ID, func(current *LobbyGame) *LobbyGame {
+		records := make(map[string]LobbyPlayer, len(current.Players))
+		for _, player := range current.Players {
+			records[player.Username] = player
+		}
+		return lobbyGame(snapshot, records, spectators)
+	})
+}
+
+// RunLobby sends lobby subscribers the online and queue counts whenever they
+// change, until ctx is cancelled. The counts move with every connection and
+// every queued player, so they are checked on a timer rather than sent on
+// each change.
+func (h *Handler) RunLobby(ctx context.Context) {
+	ticker := time.NewTicker(lobbyCountsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			counts := LobbyCountsMessage{
+				Online: h.hub.Count(),
+				Queued: h.matchmaker.QueueLength(),
+			}
+			if h.lobby.setCounts(counts) {
+				h.hub.Broadcast(MsgLobbyCounts, &counts)
+			}
+			h.lobby.forgetEnded(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
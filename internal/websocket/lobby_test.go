@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Once a game has ended, nothing racing with its end may list it again.
+func TestLobbyEnded(t *testing.T) {
+	tests := []struct {
+		name string
+		// steps run in order on game g1: "add", "update" or "end".
+		steps      []string
+		wantListed bool
+		wantEvents []string
+	}{
+		{"add and update", []string{"add", "update"}, true, []string{MsgLobbyGameAdded, MsgLobbyGameUpdated}},
+		{"update after the end", []string{"add", "end", "update"}, false, []string{MsgLobbyGameAdded, MsgLobbyGameRemoved}},
+		{"add after the end", []string{"end", "add"}, false, nil},
+		{"update before the add", []string{"update", "add"}, true, []string{MsgLobbyGameAdded}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			l := newLobby(func(msgType string, payload interface{}) {
+				events = append(events, msgType)
+			})
+
+			for _, step := range tt.steps {
+				switch step {
+				case "add":
+					l.add(&LobbyGame{ID: "g1"})
+				case "update":
+					l.update("g1", func(current *LobbyGame) *LobbyGame {
+						return &LobbyGame{ID: "g1", Moves: current.Moves + 1}
+					})
+				case "end":
+					l.end("g1")
+				}
+			}
+
+			if listed := len(l.list()) == 1; listed != tt.wantListed {
+				t.Errorf("listed = %v, want %v", listed, tt.wantListed)
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
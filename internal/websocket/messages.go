@@ -0,0 +1,431 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Requests sent by clients. In protocol version 1 these fields sit next to
+// the message type; in version 2 they are the envelope's payload.
+
+// matchModeTeams asks matchmaking for a 2v2 team game.
+const matchModeTeams = "teams"
+
+// FindMatchRequest queues the player for a game.
+type FindMatchRequest struct {
+	Ruleset string `json:"ruleset,omitempty"`
+	// Players is the number of players for a free-for-all game.
+	Players int    `json:"players,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+	// Partner is the username of the teammate in a team game.
+	Partner string `json:"partner,omitempty"`
+}
+
+// StartPracticeRequest starts a game against the bot.
+type StartPracticeRequest struct {
+	Settings models.GameSettings `json:"settings"`
+}
+
+// MakeMoveRequest drops a piece into a column.
+type MakeMoveRequest struct {
+	Column *int `json:"column"`
+}
+
+// UsePowerUpRequest plays a power-up piece in an arcade game.
+type UsePowerUpRequest struct {
+	PowerUp string `json:"powerup"`
+	Column  *int   `json:"column"`
+}
+
+// ResignRequest gives up the game.
+type ResignRequest struct{}
+
+// SwapRequest takes over the opponent's opening move.
+type SwapRequest struct{}
+
+// TeamChatRequest sends a message to the player's team.
+type TeamChatRequest struct {
+	Text string `json:"text"`
+}
+
+// ChatRequest sends a message to the players of the sender's game or, from
+// a spectator, to the other spectators.
+type ChatRequest struct {
+	Text string `json:"text"`
+}
+
+// EmoteRequest sends one of the fixed emotes to the sender's game.
+type EmoteRequest struct {
+	Emote string `json:"emote"`
+}
+
+// SetEmotesRequest chooses whether the client is sent emotes.
+type SetEmotesRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// MuteRequest hides a player's chat until the connection closes.
+type MuteRequest struct {
+	Username string `json:"username"`
+}
+
+// UnmuteRequest shows a muted player's chat again.
+type UnmuteRequest struct {
+	Username string `json:"username"`
+}
+
+// BlockRequest hides a player's chat for good.
+type BlockRequest struct {
+	Username string `json:"username"`
+}
+
+// UnblockRequest lifts a block.
+type UnblockRequest struct {
+	Username string `json:"username"`
+}
+
+// RequestTakebackRequest asks the opponent to undo the last move.
+type RequestTakebackRequest struct{}
+
+// RespondTakebackRequest answers a takeback request.
+type RespondTakebackRequest struct {
+	Accept bool `json:"accept"`
+}
+
+// RejoinRequest reclaims a seat after a reconnect.
+type RejoinRequest struct {
+	ResumeToken string `json:"resume_token"`
+	// GameID is sent by older clients; it has to agree with the token.
+	GameID   string `json:"game_id,omitempty"`
+	LastMove int    `json:"last_move,omitempty"`
+	// LastSeq is the last game event the client applied. When given, the
+	// events after it are replayed instead of sending the whole game.
+	LastSeq int64 `json:"last_seq,omitempty"`
+}
+
+// AckRequest acknowledges the game events up to Seq.
+type AckRequest struct {
+	Seq int64 `json:"seq"`
+}
+
+// ResyncRequest asks for the whole game again, for a client that has missed
+// events.
+type ResyncRequest struct{}
+
+// SpectateRequest starts watching a live game, named by its ID or by a share
+// link.
+type SpectateRequest struct {
+	GameID string `json:"game_id,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// StopSpectatingRequest stops watching.
+type StopSpectatingRequest struct{}
+
+// OfferRematchRequest offers the opponent of a finished game a rematch.
+type OfferRematchRequest struct{}
+
+// AcceptRematchRequest accepts the opponent's rematch offer.
+type AcceptRematchRequest struct{}
+
+// DeclineRematchRequest turns the opponent's rematch offer down.
+type DeclineRematchRequest struct{}
+
+// CreatePrivateRequest opens an invite to a private game with the given
+// settings.
+type CreatePrivateRequest struct {
+	Ruleset string `json:"ruleset,omitempty"`
+	// Mode is rated or casual.
+	Mode        string `json:"mode,omitempty"`
+	TurnSeconds int    `json:"turn_seconds,omitempty"`
+	// FirstMover is creator, joiner or random; the creator moves first by
+	// default.
+	FirstMover string `json:"first_mover,omitempty"`
+}
+
+// JoinPrivateRequest joins a private game by its invite code or link.
+type JoinPrivateRequest struct {
+	Code string `json:"code"`
+}
+
+// CancelPrivateRequest withdraws the client's open invite.
+type CancelPrivateRequest struct{}
+
+// SubscribeLobbyRequest starts the lobby feed of live games.
+type SubscribeLobbyRequest struct{}
+
+// UnsubscribeLobbyRequest stops the lobby feed.
+type UnsubscribeLobbyRequest struct{}
+
+// requestTypes maps each inbound message type to its request struct.
+var requestTypes = map[string]func() interface{}{
+	"find_match":        func() interface{} { return &FindMatchRequest{} },
+	"start_practice":    func() interface{} { return &StartPracticeRequest{} },
+	"make_move":         func() interface{} { return &MakeMoveRequest{} },
+	"use_powerup":       func() interface{} { return &UsePowerUpRequest{} },
+	"resign":            func() interface{} { return &ResignRequest{} },
+	"swap":              func() interface{} { return &SwapRequest{} },
+	"team_chat":         func() interface{} { return &TeamChatRequest{} },
+	"chat":              func() interface{} { return &ChatRequest{} },
+	"emote":             func() interface{} { return &EmoteRequest{} },
+	"set_emotes":        func() interface{} { return &SetEmotesRequest{} },
+	"mute":              func() interface{} { return &MuteRequest{} },
+	"unmute":            func() interface{} { return &UnmuteRequest{} },
+	"block":             func() interface{} { return &BlockRequest{} },
+	"unblock":           func() interface{} { return &UnblockRequest{} },
+	"request_takeback":  func() interface{} { return &RequestTakebackRequest{} },
+	"respond_takeback":  func() interface{} { return &RespondTakebackRequest{} },
+	"rejoin":            func() interface{} { return &RejoinRequest{} },
+	"ack":               func() interface{} { return &AckRequest{} },
+	"resync":            func() interface{} { return &ResyncRequest{} },
+	"spectate":          func() interface{} { return &SpectateRequest{} },
+	"stop_spectating":   func() interface{} { return &StopSpectatingRequest{} },
+	"offer_rematch":     func() interface{} { return &OfferRematchRequest{} },
+	"accept_rematch":    func() interface{} { return &AcceptRematchRequest{} },
+	"decline_rematch":   func() interface{} { return &DeclineRematchRequest{} },
+	"create_private":    func() interface{} { return &CreatePrivateRequest{} },
+	"join_private":      func() interface{} { return &JoinPrivateRequest{} },
+	"cancel_private":    func() interface{} { return &CancelPrivateRequest{} },
+	"subscribe_lobby":   func() interface{} { return &SubscribeLobbyRequest{} },
+	"unsubscribe_lobby": func() interface{} { return &UnsubscribeLobbyRequest{} },
+}
+
+// Messages sent by the server, named by their message type.
+const (
+	MsgWelcome           = "welcome"
+	MsgError             = "error"
+	MsgGameStart         = "game_start"
+	MsgMoveMade          = "move_made"
+	MsgPowerUpUsed       = "powerup_used"
+	MsgTakebackRequested = "takeback_requested"
+	MsgTakebackDeclined  = "takeback_declined"
+	MsgTakeback          = "takeback"
+	MsgPlayerEliminated  = "player_eliminated"
+	MsgSwap              = "swap"
+	MsgTeamChat          = "team_chat"
+	MsgChat              = "chat"
+	MsgChatSettings      = "chat_settings"
+	MsgEmote             = "emote"
+	MsgGameEnd           = "game_end"
+	MsgMatchEnd          = "match_end"
+	MsgRejoinSuccess     = "rejoin_success"
+	MsgResyncState       = "resync_state"
+	MsgSpectateStart     = "spectate_start"
+	MsgSpectatorCount    = "spectator_count"
+	MsgRematchOffered    = "rematch_offered"
+	MsgRematchDeclined   = "rematch_declined"
+	MsgPrivateCreated    = "private_created"
+	MsgLobbySnapshot     = "lobby_snapshot"
+	MsgLobbyGameAdded    = "lobby_game_added"
+	MsgLobbyGameUpdated  = "lobby_game_updated"
+	MsgLobbyGameRemoved  = "lobby_game_removed"
+	MsgLobbyCounts       = "lobby_counts"
+)
+
+// WelcomeMessage opens a version 2 connection.
+type WelcomeMessage struct {
+	Version  int    `json:"version"`
+	Versions []int  `json:"versions"`
+	PlayerID string `json:"player_id"`
+}
+
+// ErrorMessage reports a request that failed. Code is one of the E_ codes.
+type ErrorMessage struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// GameStartMessage tells a player a game has begun, with the resume token
+// that reclaims the seat after a reconnect.
+type GameStartMessage struct {
+	Game        *models.Game `json:"game"`
+	Seat        int          `json:"seat"`
+	ResumeToken string       `json:"resume_token"`
+	// ShareLink lets others watch the game.
+	ShareLink string `json:"share_link"`
+	// HeadToHead is the players' score against each other before this
+	// game; it is left out of group games.
+	HeadToHead *models.HeadToHead `json:"head_to_head,omitempty"`
+	// Seq is the game event the game is at.
+	Seq int64 `json:"seq"`
+}
+
+// MoveMessage reports a move or, with PowerUp set, a power-up. Changes lists
+// every cell the move changed.
+type MoveMessage struct {
+	Column  int                 `json:"column"`
+	Row     int                 `json:"row"`
+	Player  int                 `json:"player"`
+	Changes []models.CellChange `json:"changes"`
+	PowerUp string              `json:"powerup,omitempty"`
+	Game    *models.Game        `json:"game"`
+}
+
+// TakebackMessage reports a takeback request, its refusal, or the takeback
+// itself with the entries it recorded.
+type TakebackMessage struct {
+	Seat   int           `json:"seat"`
+	Game   *models.Game  `json:"game"`
+	Undone []models.Move `json:"undone,omitempty"`
+}
+
+// EliminatedMessage reports a player knocked out of a free-for-all game.
+type EliminatedMessage struct {
+	Seat   int          `json:"seat"`
+	Reason string       `json:"reason"`
+	Game   *models.Game `json:"game"`
+}
+
+// SwapMessage gives a player their seat after the swap.
+type SwapMessage struct {
+	Seat int          `json:"seat"`
+	Game *models.Game `json:"game"`
+}
+
+// TeamChatMessage relays a teammate's message.
+type TeamChatMessage struct {
+	From string `json:"from"`
+	Seat int    `json:"seat"`
+	Text string `json:"text"`
+}
+
+// ChatMessage relays a chat message. Seat is 0 for a spectator.
+type ChatMessage struct {
+	Channel string    `json:"channel"`
+	From    string    `json:"from"`
+	Seat    int       `json:"seat,omitempty"`
+	Text    string    `json:"text"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// ChatSettingsMessage lists the players whose chat the client hides.
+type ChatSettingsMessage struct {
+	Muted   []string `json:"muted"`
+	Blocked []string `json:"blocked"`
+}
+
+// EmoteMessage relays an emote from a player.
+type EmoteMessage struct {
+	From  string `json:"from"`
+	Seat  int    `json:"seat"`
+	Emote string `json:"emote"`
+}
+
+// RematchMessage reports a rematch offer, or its refusal, from the opponent.
+type RematchMessage struct {
+	GameID string `json:"game_id"`
+	From   string `json:"from"`
+}
+
+// PrivateCreatedMessage gives the creator of a private game the code and
+// link to pass on.
+type PrivateCreatedMessage struct {
+	Code      string              `json:"code"`
+	Link      string              `json:"link"`
+	ExpiresAt time.Time           `json:"expires_at"`
+	Settings  models.GameSettings `json:"settings"`
+}
+
+// GameEndMessage reports how a game ended.
+type GameEndMessage struct {
+	Result string         `json:"result"`
+	Winner *models.Player `json:"winner"`
+	Game   *models.Game   `json:"game"`
+}
+
+// MatchEndMessage reports the score of a finished mini-match.
+type MatchEndMessage struct {
+	MatchID string             `json:"match_id"`
+	Opening string             `json:"opening"`
+	Scores  map[string]float64 `json:"scores"`
+}
+
+// SpectateStartMessage gives a new spectator the game as it stands.
+type SpectateStartMessage struct {
+	Game       *models.Game `json:"game"`
+	Spectators int          `json:"spectators"`
+	ShareLink  string       `json:"share_link"`
+	Seq        int64        `json:"seq"`
+}
+
+// SpectatorCountMessage tells everyone in a game how many are watching.
+type SpectatorCountMessage struct {
+	GameID     string `json:"game_id"`
+	Spectators int    `json:"spectators"`
+}
+
+// RejoinMessage returns a player to their game with the moves they missed.
+// A client that gave its last sequence number gets the events after it
+// replayed straight after this message, and no game, unless they are no
+// longer held; then Resync is set and the whole game is sent.
+type RejoinMessage struct {
+	Game        *models.Game  `json:"game,omitempty"`
+	Seat        int           `json:"seat"`
+	ResumeToken string        `json:"resume_token"`
+	MissedMoves []models.Move `json:"missed_moves"`
+	Spectators  int           `json:"spectators"`
+	// Seq is the game event the client is at once it has this message.
+	Seq    int64 `json:"seq"`
+	Replay int   `json:"replay,omitempty"`
+	Resync bool  `json:"resync,omitempty"`
+}
+
+// ResyncMessage gives a client the whole game as of a game event. Seat is 0
+// for a spectator.
+type ResyncMessage struct {
+	Game       *models.Game `json:"game"`
+	Seat       int          `json:"seat"`
+	Spectators int          `json:"spectators"`
+	Seq        int64        `json:"seq"`
+}
+
+// LobbyGame is a live game as the lobby lists it.
+type LobbyGame struct {
+	ID      string        `json:"id"`
+	Players []LobbyPlayer `json:"players"`
+	// Moves counts the moves on the board.
+	Moves      int       `json:"moves"`
+	Variant    string    `json:"variant"`
+	Seats      int       `json:"seats"`
+	Teams      bool      `json:"teams,omitempty"`
+	Mode       string    `json:"mode,omitempty"`
+	IsBot      bool      `json:"is_bot"`
+	Spectators int       `json:"spectators"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// LobbyPlayer is a player in a lobby game with their rated record, which is
+// the standing the server keeps for a player.
+type LobbyPlayer struct {
+	Username string `json:"username"`
+	Seat     int    `json:"seat"`
+	Wins     int    `json:"wins"`
+	Losses   int    `json:"losses"`
+	Draws    int    `json:"draws"`
+}
+
+// LobbySnapshotMessage gives a new lobby subscriber every live game and the
+// current counts.
+type LobbySnapshotMessage struct {
+	Games  []*LobbyGame `json:"games"`
+	Online int          `json:"online"`
+	Queued int          `json:"queued"`
+}
+
+// LobbyGameMessage reports a game added to the lobby or a change to one.
+type LobbyGameMessage struct {
+	Game *LobbyGame `json:"game"`
+}
+
+// LobbyGameRemovedMessage reports a game that has left the lobby.
+type LobbyGameRemovedMessage struct {
+	GameID string `json:"game_id"`
+}
+
+// LobbyCountsMessage reports how many players are online and queued.
+type LobbyCountsMessage struct {
+	Online int `json:"online"`
+	Queued int `json:"queued"`
+}
@@ -0,0 +1,462 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// Two wire protocols are spoken, chosen when the connection opens.
+//
+// Version 1 is the original format: flat JSON objects with a "type" field
+// next to the message's own fields. It is used when the client asks for no
+// subprotocol, so existing clients keep working unchanged.
+//
+// Version 2 wraps every message in an envelope:
+//
+//	{"type": "make_move", "version": 2, "id": "17", "payload": {"column": 3}}
+//
+// Requests are decoded strictly: unknown types, unknown fields and fields of
+// the wrong type are rejected with an error carrying a code, and the error
+// echoes the id of the request it answers. Clients ask for version 2 by
+// offering the ProtocolV2Name subprotocol.
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+
+	ProtocolV1Name = "fourinarow.v1"
+	ProtocolV2Name = "fourinarow.v2"
+)
+
+// supportedVersions lists the protocol versions the server speaks.
+var supportedVersions = []int{ProtocolV1, ProtocolV2}
+
+// Error codes for requests the server could not accept. Gameplay errors
+// use the codes of the game package.
+const (
+	CodeMalformed          = "E_MALFORMED"
+	CodeUnsupportedVersion = "E_UNSUPPORTED_VERSION"
+	CodeUnknownType        = "E_UNKNOWN_TYPE"
+	CodeInvalidPayload     = "E_INVALID_PAYLOAD"
+	CodeInvalidToken       = "E_INVALID_TOKEN"
+	CodeTokenExpired       = "E_TOKEN_EXPIRED"
+	CodeTokenMismatch      = "E_TOKEN_MISMATCH"
+	CodeTeamChatOnly       = "E_TEAM_GAME_ONLY"
+	CodeGroupNotFilled     = "E_GROUP_NOT_FILLED"
+	CodeSpectatorLimit     = "E_SPECTATOR_LIMIT"
+	CodeChatRateLimited    = "E_CHAT_RATE_LIMITED"
+	CodeChatDisabled       = "E_CHAT_DISABLED"
+	CodeEmoteCooldown      = "E_EMOTE_COOLDOWN"
+	CodeRematchUnavailable = "E_REMATCH_UNAVAILABLE"
+	CodeNoRematchOffer     = "E_NO_REMATCH_OFFER"
+	CodeInviteNotFound     = "E_INVITE_NOT_FOUND"
+	CodeInviteExpired      = "E_INVITE_EXPIRED"
+	CodeInviteOwn          = "E_INVITE_OWN"
+	CodeAlreadyPlaying     = "E_ALREADY_PLAYING"
+	CodeRequestFailed      = "E_REQUEST_FAILED"
+)
+
+// protocolCatalog describes the connection-level error codes.
+var protocolCatalog = []ErrorMessage{
+	{Code: CodeMalformed, Message: "message is not valid JSON or not a valid envelope"},
+	{Code: CodeUnsupportedVersion, Message: "protocol version is not supported on this connection"},
+	{Code: CodeUnknownType, Message: "unknown message type"},
+	{Code: CodeInvalidPayload, Message: "message fields are missing, unknown or invalid"},
+	{Code: CodeInvalidToken, Message: "invalid resume token"},
+	{Code: CodeTokenExpired, Message: "resume token expired"},
+	{Code: CodeTokenMismatch, Message: "resume token is for another game or player"},
+	{Code: CodeTeamChatOnly, Message: "only available in team games"},
+	{Code: CodeGroupNotFilled, Message: "not enough players for a group game, try again later"},
+	{Code: CodeSpectatorLimit, Message: "this game has as many spectators as it can take"},
+	{Code: CodeChatRateLimited, Message: "sending messages too fast, slow down"},
+	{Code: CodeChatDisabled, Message: "spectator chat is turned off"},
+	{Code: CodeEmoteCooldown, Message: "wait a moment before sending another emote"},
+	{Code: CodeRematchUnavailable, Message: "a rematch is not possible for this game"},
+	{Code: CodeNoRematchOffer, Message: "no rematch offer to answer"},
+	{Code: CodeInviteNotFound, Message: "no such invite, or it has already been used"},
+	{Code: CodeInviteExpired, Message: "this invite has expired"},
+	{Code: CodeInviteOwn, Message: "you cannot join your own invite"},
+	{Code: CodeAlreadyPlaying, Message: "finish your current game before joining another"},
+	{Code: CodeRequestFailed, Message: "the request could not be carried out"},
+}
+
+// ErrorCatalog lists every error code a client can receive with its default
+// message, for clients that localize errors.
+func ErrorCatalog() []ErrorMessage {
+	codes := make([]ErrorMessage, 0, len(protocolCatalog)+len(game.Catalog()))
+	for _, err := range game.Catalog() {
+		codes = append(codes, ErrorMessage{Code: err.Code, Message: err.Message})
+	}
+	return append(codes, protocolCatalog...)
+}
+
+// ProtocolError is a request error with a machine-readable code.
+type ProtocolError struct {
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Message
+}
+
+func invalidPayload(format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: CodeInvalidPayload, Message: fmt.Sprintf(format, args...)}
+}
+
+// Envelope is the version 2 frame around every message. Seq numbers the
+// events of a game; see events.go.
+type Envelope struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	ID      string          `json:"id,omitempty"`
+	Seq     int64           `json:"seq,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// request is a decoded inbound message. Payload points to one of the request
+// structs in messages.go.
+type request struct {
+	Type    string
+	ID      string
+	Payload interface{}
+}
+
+// validator is implemented by requests with rules beyond their field types.
+type validator interface {
+	validate() error
+}
+
+// negotiateProtocol picks the protocol version for a new connection from the
+// subprotocol the upgrade settled on.
+func negotiateProtocol(subprotocol string) int {
+	if subprotocol == ProtocolV2Name {
+		return ProtocolV2
+	}
+	return ProtocolV1
+}
+
+// decodeRequest parses an inbound message in the client's protocol version.
+// The returned request carries the message ID even when decoding fails, so
+// the error can be matched to the request.
+func decodeRequest(protocol int, data []byte) (*request, error) {
+	if protocol == ProtocolV2 {
+		return decodeEnvelope(data)
+	}
+	return decodeFlat(data)
+}
+
+func decodeEnvelope(data []byte) (*request, error) {
+	var env Envelope
+	if err := strictUnmarshal(data, &env); err != nil {
+		return &request{}, &ProtocolError{Code: CodeMalformed, Message: "message is not a valid envelope: " + err.Error()}
+	}
+
+	req := &request{Type: env.Type, ID: env.ID}
+	if env.Version != ProtocolV2 {
+		return req, &ProtocolError{
+			Code:    CodeUnsupportedVersion,
+			Message: fmt.Sprintf("version %d is not supported on this connection", env.Version),
+			Details: map[string]interface{}{"supported": []int{ProtocolV2}},
+		}
+	}
+
+	payload, err := newRequestPayload(env.Type)
+	if err != nil {
+		return req, err
+	}
+
+	raw := env.Payload
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = json.RawMessage("{}")
+	}
+	if err := strictUnmarshal(raw, payload); err != nil {
+		return req, invalidPayload("%s: %v", env.Type, err)
+	}
+
+	req.Payload = payload
+	return req, validate(req)
+}
+
+// decodeFlat reads a version 1 message. Old clients send fields the server
+// never used, such as the game ID next to a resume token, so unknown fields
+// are ignored here.
+func decodeFlat(data []byte) (*request, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return &request{}, &ProtocolError{Code: CodeMalformed, Message: "message is not a JSON object"}
+	}
+
+	var msgType string
+	if err := json.Unmarshal(fields["type"], &msgType); err != nil || msgType == "" {
+		return &request{}, &ProtocolError{Code: CodeMalformed, Message: "message has no type"}
+	}
+	req := &request{Type: msgType}
+
+	payload, err := newRequestPayload(msgType)
+	if err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return req, invalidPayload("%s: %v", msgType, err)
+	}
+
+	req.Payload = payload
+	return req, validate(req)
+}
+
+func validate(req *request) error {
+	if v, ok := req.Payload.(validator); ok {
+		if err := v.validate(); err != nil {
+			var protocolErr *ProtocolError
+			if errors.As(err, &protocolErr) {
+				return protocolErr
+			}
+			return invalidPayload("%s: %v", req.Type, err)
+		}
+	}
+	return nil
+}
+
+func newRequestPayload(msgType string) (interface{}, error) {
+	newPayload, ok := requestTypes[msgType]
+	if !ok {
+		return nil, &ProtocolError{
+			Code:    CodeUnknownType,
+			Message: fmt.Sprintf("unknown message type %q", msgType),
+		}
+	}
+	return newPayload(), nil
+}
+
+// strictUnmarshal decodes a single JSON value, rejecting unknown fields and
+// trailing data.
+func strictUnmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the message")
+	}
+	return nil
+}
+
+// encodeMessage writes an outbound message in the given protocol version.
+// id is the request the message answers, if any; version 1 has nowhere to
+// put it. seq is the sequence number of a game event, or 0 for other
+// messages.
+func encodeMessage(protocol int, msgType, id string, seq int64, payload interface{}) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ProtocolV2 {
+		return json.Marshal(Envelope{
+			Type:    msgType,
+			Version: ProtocolV2,
+			ID:      id,
+			Seq:     seq,
+			Payload: body,
+		})
+	}
+
+	// Version 1 puts the type next to the payload's own fields
+	typeField, err := json.Marshal(msgType)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`{"type":`)
+	buf.Write(typeField)
+	if seq != 0 {
+		buf.WriteString(`,"seq":`)
+		buf.WriteString(strconv.FormatInt(seq, 10))
+	}
+	// A nil payload adds no fields; anything but an object has no fields to
+	// put the type beside
+	fields := []byte(nil)
+	switch {
+	case bytes.Equal(body, []byte("null")):
+	case len(body) >= 2 && body[0] == '{':
+		fields = bytes.TrimSpace(body[1 : len(body)-1])
+	default:
+		return nil, fmt.Errorf("%s payload must be a JSON object, got %s", msgType, body)
+	}
+	if len(fields) > 0 {
+		buf.WriteByte(',')
+		buf.Write(fields)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// errorMessage converts a handler error into the error sent to the client.
+// Errors that carry no code of their own are reported as failed requests.
+func errorMessage(err error) *ErrorMessage {
+	var protocolErr *ProtocolError
+	if errors.As(err, &protocolErr) {
+		return &ErrorMessage{
+			Code:    protocolErr.Code,
+			Message: protocolErr.Message,
+			Details: protocolErr.Details,
+		}
+	}
+	var gameErr *game.Error
+	if errors.As(err, &gameErr) {
+		return &ErrorMessage{
+			Code:    gameErr.Code,
+			Message: gameErr.Message,
+			Details: gameErr.Details,
+		}
+	}
+	return &ErrorMessage{Code: CodeRequestFailed, Message: err.Error()}
+}
+
+// Validation of the request structs.
+
+func (r *FindMatchRequest) validate() error {
+	if r.Players != 0 && (r.Players < 2 || r.Players > game.MaxSeats) {
+		return invalidPayload("players must be between 2 and %d", game.MaxSeats)
+	}
+	switch r.Mode {
+	case "", matchModeTeams:
+	default:
+		return invalidPayload("unknown mode %q", r.Mode)
+	}
+	if r.Partner != "" && r.Mode != matchModeTeams {
+		return invalidPayload("a partner can only be named for a team game")
+	}
+	return nil
+}
+
+func (r *MakeMoveRequest) validate() error {
+	if r.Column == nil {
+		return invalidPayload("column is required")
+	}
+	if *r.Column < 0 {
+		return invalidPayload("column must not be negative")
+	}
+	return nil
+}
+
+func (r *UsePowerUpRequest) validate() error {
+	if !game.IsPowerUp(r.PowerUp) {
+		return invalidPayload("unknown power-up %q", r.PowerUp)
+	}
+	if r.Column == nil {
+		return invalidPayload("column is required")
+	}
+	if *r.Column < 0 {
+		return invalidPayload("column must not be negative")
+	}
+	return nil
+}
+
+func (r *TeamChatRequest) validate() error {
+	return validateChatText(&r.Text)
+}
+
+func (r *ChatRequest) validate() error {
+	return validateChatText(&r.Text)
+}
+
+func (r *EmoteRequest) validate() error {
+	if !isEmote(r.Emote) {
+		return &ProtocolError{
+			Code:    CodeInvalidPayload,
+			Message: fmt.Sprintf("unknown emote %q", r.Emote),
+			Details: map[string]interface{}{"emotes": models.Emotes},
+		}
+	}
+	return nil
+}
+
+func (r *CreatePrivateRequest) validate() error {
+	switch r.Mode {
+	case "", models.ModeRated, models.ModeCasual:
+	default:
+		return invalidPayload("mode must be %s or %s", models.ModeRated, models.ModeCasual)
+	}
+	switch r.FirstMover {
+	case "", firstMoverCreator, firstMoverJoiner, firstMoverRandom:
+	default:
+		return invalidPayload("first_mover must be %s, %s or %s", firstMoverCreator, firstMoverJoiner, firstMoverRandom)
+	}
+	return nil
+}
+
+func (r *JoinPrivateRequest) validate() error {
+	r.Code = parseInviteCode(r.Code)
+	if r.Code == "" {
+		return invalidPayload("code is required")
+	}
+	return nil
+}
+
+func (r *MuteRequest) validate() error {
+	return validateUsername(&r.Username)
+}
+
+func (r *UnmuteRequest) validate() error {
+	return validateUsername(&r.Username)
+}
+
+func (r *BlockRequest) validate() error {
+	return validateUsername(&r.Username)
+}
+
+func (r *UnblockRequest) validate() error {
+	return validateUsername(&r.Username)
+}
+
+func validateUsername(username *string) error {
+	*username = strings.TrimSpace(*username)
+	if *username == "" {
+		return invalidPayload("username is required")
+	}
+	return nil
+}
+
+func (r *SpectateRequest) validate() error {
+	if (r.GameID == "") == (r.Link == "") {
+		return invalidPayload("give either a game_id or a link")
+	}
+	if r.Link != "" {
+		id, ok := parseShareLink(r.Link)
+		if !ok {
+			return invalidPayload("%q is not a link to a game", r.Link)
+		}
+		r.GameID = id
+	}
+	return nil
+}
+
+func (r *RejoinRequest) validate() error {
+	if r.ResumeToken == "" {
+		return invalidPayload("resume token required")
+	}
+	if r.LastMove < 0 {
+		return invalidPayload("last_move must not be negative")
+	}
+	if r.LastSeq < 0 {
+		return invalidPayload("last_seq must not be negative")
+	}
+	return nil
+}
+
+func (r *AckRequest) validate() error {
+	if r.Seq < 0 {
+		return invalidPayload("seq must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package websocket
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol int
+		data     string
+		wantType string
+		wantID   string
+		// want is the decoded payload, checked when there is no error.
+		want     interface{}
+		wantCode string
+	}{
+		{
+			name:     "v1 move",
+			protocol: ProtocolV1,
+			data:     `{"type":"make_move","column":3}`,
+			wantType: "make_move",
+			want:     &MakeMoveRequest{Column: intPtr(3)},
+		},
+		{
+			name:     "v1 ignores unknown fields",
+			protocol: ProtocolV1,
+			data:     `{"type":"make_move","column":0,"game_id":"g1"}`,
+			wantType: "make_move",
+			want:     &MakeMoveRequest{Column: intPtr(0)},
+		},
+		{
+			name:     "v1 not an object",
+			protocol: ProtocolV1,
+			data:     `[1,2]`,
+			wantCode: CodeMalformed,
+		},
+		{
+			name:     "v1 without type",
+			protocol: ProtocolV1,
+			data:     `{"column":3}`,
+			wantCode: CodeMalformed,
+		},
+		{
+			name:     "v1 unknown type",
+			protocol: ProtocolV1,
+			data:     `{"type":"fly"}`,
+			wantType: "fly",
+			wantCode: CodeUnknownType,
+		},
+		{
+			name:     "v1 wrong field type",
+			protocol: ProtocolV1,
+			data:     `{"type":"make_move","column":"3"}`,
+			wantType: "make_move",
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "v1 missing column",
+			protocol: ProtocolV1,
+			data:     `{"type":"make_move"}`,
+			wantType: "make_move",
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "v2 move",
+			protocol: ProtocolV2,
+			data:     `{"type":"make_move","version":2,"id":"r1","payload":{"column":6}}`,
+			wantType: "make_move",
+			wantID:   "r1",
+			want:     &MakeMoveRequest{Column: intPtr(6)},
+		},
+		{
+			name:     "v2 without payload",
+			protocol: ProtocolV2,
+			data:     `{"type":"resync","version":2}`,
+			wantType: "resync",
+			want:     &ResyncRequest{},
+		},
+		{
+			name:     "v2 normalises invite codes",
+			protocol: ProtocolV2,
+			data:     `{"type":"join_private","version":2,"payload":{"code":" https://example.com/join/abc234 "}}`,
+			wantType: "join_private",
+			want:     &JoinPrivateRequest{Code: "ABC234"},
+		},
+		{
+			name:     "v2 unknown envelope field",
+			protocol: ProtocolV2,
+			data:     `{"type":"make_move","version":2,"extra":true,"payload":{"column":1}}`,
+			wantCode: CodeMalformed,
+		},
+		{
+			name:     "v2 trailing data",
+			protocol: ProtocolV2,
+			data:     `{"type":"resync","version":2} {}`,
+			wantCode: CodeMalformed,
+		},
+		{
+			name:     "v2 wrong version",
+			protocol: ProtocolV2,
+			data:     `{"type":"make_move","version":1,"id":"r2","payload":{"column":1}}`,
+			wantType: "make_move",
+			wantID:   "r2",
+			wantCode: CodeUnsupportedVersion,
+		},
+		{
+			name:     "v2 unknown payload field",
+			protocol: ProtocolV2,
+			data:     `{"type":"make_move","version":2,"payload":{"column":1,"row":2}}`,
+			wantType: "make_move",
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "v2 negative column",
+			protocol: ProtocolV2,
+			data:     `{"type":"make_move","version":2,"payload":{"column":-1}}`,
+			wantType: "make_move",
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "v2 negative ack",
+			protocol: ProtocolV2,
+			data:     `{"type":"ack","version":2,"payload":{"seq":-5}}`,
+			wantType: "ack",
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "v2 team partner outside team mode",
+			protocol: ProtocolV2,
+			data:     `{"type":"find_match","version":2,"payload":{"partner":"bob"}}`,
+			wantType: "find_match",
+			wantCode: CodeInvalidPayload,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := decodeRequest(tt.protocol, []byte(tt.data))
+			if req == nil {
+				t.Fatalf("decodeRequest returned no request (error %v)", err)
+			}
+			if req.Type != tt.wantType || req.ID != tt.wantID {
+				t.Errorf("type %q, id %q; want %q, %q", req.Type, req.ID, tt.wantType, tt.wantID)
+			}
+
+			if tt.wantCode != "" {
+				var protocolErr *ProtocolError
+				if !errors.As(err, &protocolErr) || protocolErr.Code != tt.wantCode {
+					t.Errorf("error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeRequest: %v", err)
+			}
+			if !reflect.DeepEqual(req.Payload, tt.want) {
+				t.Errorf("payload = %#v, want %#v", req.Payload, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	count := &SpectatorCountMessage{GameID: "g1", Spectators: 2}
+	tests := []struct {
+		name     string
+		protocol int
+		id       string
+		seq      int64
+		payload  interface{}
+		// want is the encoded message, or "" if encoding must fail.
+		want string
+	}{
+		{"v1", ProtocolV1, "r1", 0, count, `{"type":"spectator_count","game_id":"g1","spectators":2}`},
+		{"v1 event", ProtocolV1, "", 7, count, `{"type":"spectator_count","seq":7,"game_id":"g1","spectators":2}`},
+		{"v1 null payload", ProtocolV1, "", 7, nil, `{"type":"spectator_count","seq":7}`},
+		{"v1 empty object", ProtocolV1, "", 0, struct{}{}, `{"type":"spectator_count"}`},
+		{"v1 string payload", ProtocolV1, "", 0, "g1", ""},
+		{"v1 array payload", ProtocolV1, "", 0, []int{1, 2}, ""},
+		{"v2", ProtocolV2, "r1", 0, count, `{"type":"spectator_count","version":2,"id":"r1","payload":{"game_id":"g1","spectators":2}}`},
+		{"v2 event", ProtocolV2, "", 7, count, `{"type":"spectator_count","version":2,"seq":7,"payload":{"game_id":"g1","spectators":2}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := encodeMessage(tt.protocol, MsgSpectatorCount, tt.id, tt.seq, tt.payload)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("encodeMessage = %s, want an error", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encodeMessage: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("encodeMessage = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+// Every error code declared in protocol.go must be in the catalog.
+func TestErrorCatalog(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "protocol.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := map[string]bool{}
+	for _, entry := range ErrorCatalog() {
+		if listed[entry.Code] {
+			t.Errorf("code %s is listed twice", entry.Code)
+		}
+		listed[entry.Code] = true
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if !strings.HasPrefix(name.Name, "Code") || i >= len(spec.Values) {
+				continue
+			}
+			lit, ok := spec.Values[i].(*ast.BasicLit)
+			if !ok {
+				continue
+			}
+			code, _ := strconv.Unquote(lit.Value)
+			if !listed[code] {
+				t.Errorf("%s (%s) is missing from the error catalog", name.Name, code)
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,212 @@
+package websocket
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/internal/game"
+	"github.com/AkshatPandey-2004/4-IN-A-ROW/pkg/models"
+)
+
+// After a two-player game ends either player can offer a rematch. Once the
+// other accepts, a new game starts between the same two with the colours
+// swapped. The bot accepts straight away and keeps its difficulty.
+
+// rematchOfferTimeout is how long an unanswered rematch offer stands.
+const rematchOfferTimeout = 2 * time.Minute
+
+// rematches holds the standing offers, by game, naming the player who made
+// each.
+type rematches struct {
+	mu     sync.Mutex
+	offers map[string]string
+}
+
+func newRematches() *rematches {
+	return &rematches{offers: make(map[string]string)}
+}
+
+// offer records an offer from the player. If the opponent had already
+// offered, the two offers make a match and true is returned.
+func (r *rematches) offer(gameID, playerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if from, ok := r.offers[gameID]; ok && from != playerID {
+		delete(r.offers, gameID)
+		return true
+	}
+	r.offers[gameID] = playerID
+
+	time.AfterFunc(rematchOfferTimeout, func() {
+		r.withdraw(gameID, playerID)
+	})
+	return false
+}
+
+// take removes the opponent's offer for the player to answer, reporting
+// whether there was one.
+func (r *rematches) take(gameID, playerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	from, ok := r.offers[gameID]
+	if !ok || from == playerID {
+		return false
+	}
+	delete(r.offers, gameID)
+	return true
+}
+
+// withdraw removes the player's own offer, if it still stands.
+func (r *rematches) withdraw(gameID, playerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.offers[gameID] == playerID {
+		delete(r.offers, gameID)
+	}
+}
+
+// finishedGame returns the finished two-player game the client last played.
+func (h *Handler) finishedGame(client *Client) (*models.Game, error) {
+	gameID := client.GameID()
+	if gameID == "" {
+		return nil, game.ErrNotInGame
+	}
+	gameInstance, exists := h.gameManager.GetGame(gameID)
+	if !exists {
+		return nil, game.ErrGameNotFound.With(map[string]interface{}{"game_id": gameID})
+	}
+	snapshot := gameInstance.Snapshot()
+	if snapshot == nil {
+		return nil, game.ErrGameClosed
+	}
+	if game.SeatOf(snapshot, client.ID()) == 0 {
+		return nil, game.ErrNotInGame
+	}
+	if snapshot.Status != models.StatusFinished || game.SeatCount(snapshot) != 2 {
+		return nil, &ProtocolError{
+			Code:    CodeRematchUnavailable,
+			Message: "only finished two-player games can be rematched",
+			Details: map[string]interface{}{"status": snapshot.Status},
+		}
+	}
+	// The first game of a mini-match is followed by the return game anyway
+	if snapshot.Settings.MatchGame == 1 {
+		return nil, &ProtocolError{Code: CodeRematchUnavailable, Message: "the return game of the match is about to start"}
+	}
+	return snapshot, nil
+}
+
+// opponentOf returns the other player of a two-player game.
+func opponentOf(snapshot *models.Game, playerID string) *models.Player {
+	if snapshot.Player1.ID == playerID {
+		return snapshot.Player2
+	}
+	return snapshot.Player1
+}
+
+func (h *Handler) handleOfferRematch(client *Client) error {
+	snapshot, err := h.finishedGame(client)
+	if err != nil {
+		return err
+	}
+
+	opponent := opponentOf(snapshot, client.ID())
+	if strings.HasPrefix(opponent.ID, "bot-") {
+		return h.startRematch(snapshot)
+	}
+
+	opponentClient := h.hub.GetClient(opponent.ID)
+	if opponentClient == nil || opponentClient.GameID() != snapshot.ID {
+		return &ProtocolError{Code: CodeRematchUnavailable, Message: "your opponent has left"}
+	}
+
+	if h.rematches.offer(snapshot.ID, client.ID()) {
+		return h.startRematch(snapshot)
+	}
+	opponentClient.Send(MsgRematchOffered, &RematchMessage{GameID: snapshot.ID, From: client.username})
+	return nil
+}
+
+func (h *Handler) handleAcceptRematch(client *Client) error {
+	snapshot, err := h.finishedGame(client)
+	if err != nil {
+		return err
+	}
+	if !h.rematches.take(snapshot.ID, client.ID()) {
+		return &ProtocolError{Code: CodeNoRematchOffer, Message: "no rematch offer to answer"}
+	}
+	return h.startRematch(snapshot)
+}
+
+func (h *Handler) handleDeclineRematch(client *Client) error {
+	snapshot, err := h.finishedGame(client)
+	if err != nil {
+		return err
+	}
+	if !h.rematches.take(snapshot.ID, client.ID()) {
+		return &ProtocolError{Code: CodeNoRematchOffer, Message: "no rematch offer to answer"}
+	}
+
+	opponent := opponentOf(snapshot, client.ID())
+	if opponentClient := h.hub.GetClient(opponent.ID); opponentClient != nil {
+		opponentClient.Send(MsgRematchDeclined, &RematchMessage{GameID: snapshot.ID, From: client.username})
+	}
+	return nil
+}
+
+// startRematch starts the new game and seats both players in it. Players
+// who have moved on to another game since are not pulled back.
+func (h *Handler) startRematch(previous *models.Game) error {
+	var clients []*Client
+	for _, player := range []*models.Player{previous.Player1, previous.Player2} {
+		if strings.HasPrefix(player.ID, "bot-") {
+			continue
+		}
+		playerClient := h.hub.GetClient(player.ID)
+		if playerClient == nil || playerClient.GameID() != previous.ID {
+			return &ProtocolError{Code: CodeRematchUnavailable, Message: "your opponent has left"}
+		}
+		clients = append(clients, playerClient)
+	}
+	// Either player may have queued for another game since
+	for _, playerClient := range clients {
+		h.matchmaker.RemovePlayer(playerClient.ID())
+	}
+
+	next, err := game.NewRematch(previous)
+	if err != nil {
+		return err
+	}
+	if err := h.startGame(next); err != nil {
+		return err
+	}
+	log.Printf("Rematch %s follows game %s", next.ID, previous.ID)
+
+	for _, playerClient := range clients {
+		h.joinGame(playerClient, next)
+	}
+
+	if snapshot := next.Snapshot(); snapshot != nil && snapshot.IsBot && snapshot.CurrentTurn == botSeat(snapshot) {
+		go h.makeBotMove(next)
+	}
+	return nil
+}
+
+// headToHead returns the running score between the two players of a game,
+// or nil for games that are not between two players.
+func (h *Handler) headToHead(snapshot *models.Game) *models.HeadToHead {
+	if game.SeatCount(snapshot) != 2 || snapshot.Player1 == nil || snapshot.Player2 == nil {
+		return nil
+	}
+	score, err := h.db.GetHeadToHead(snapshot.Player1.Username, snapshot.Player2.Username)
+	if err != nil {
+		log.Printf("Failed to load head-to-head for game %s: %v", snapshot.ID, err)
+		return nil
+	}
+	return score
+}
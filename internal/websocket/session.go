@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidToken = &ProtocolError{Code: CodeInvalidToken, Message: "invalid resume token"}
+	errExpiredToken = &ProtocolError{Code: CodeTokenExpired, Message: "resume token expired"}
+)
+
+// SessionClaims identifies the seat a resume token was issued for.
+type SessionClaims struct {
+	GameID   string `json:"g"`
+	PlayerID string `json:"p"`
+	Username string `json:"u"`
+	Seat     int    `json:"s"`
+	Expires  int64  `json:"e"`
+}
+
+// SessionSigner issues and verifies HMAC-signed resume tokens so a player
+// can reclaim their seat after the websocket connection drops.
+type SessionSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSessionSigner creates a signer. With an empty secret a random one is
+// generated, which means tokens do not survive a server restart.
+func NewSessionSigner(secret string, ttl time.Duration) *SessionSigner {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatal("Failed to generate session secret:", err)
+		}
+		log.Println("SESSION_SECRET not set, resume tokens will not survive a restart")
+	}
+
+	return &SessionSigner{
+		secret: key,
+		ttl:    ttl,
+	}
+}
+
+func (s *SessionSigner) Issue(gameID, playerID, username string, seat int) (string, error) {
+	claims := SessionClaims{
+		GameID:   gameID,
+		PlayerID: playerID,
+		Username: username,
+		Seat:     seat,
+		Expires:  time.Now().Add(s.ttl).Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+func (s *SessionSigner) Verify(token string) (*SessionClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidToken
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	var claims SessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errInvalidToken
+	}
+
+	if time.Now().Unix() > claims.Expires {
+		return nil, errExpiredToken
+	}
+
+	return &claims, nil
+}
+
+func (s *SessionSigner) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
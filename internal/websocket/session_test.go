@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionVerify(t *testing.T) {
+	signer := NewSessionSigner("test secret", time.Hour)
+	token, err := signer.Issue("g1", "p1", "alice", 2)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	encoded, signature, _ := strings.Cut(token, ".")
+
+	// forged claims the seat of another player, signed with the old
+	// signature
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"g":"g1","p":"p2","u":"bob","s":1,"e":9999999999}`)) + "." + signature
+	expired, err := NewSessionSigner("test secret", -time.Minute).Issue("g1", "p1", "alice", 2)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		signer   *SessionSigner
+		token    string
+		wantCode string
+	}{
+		{"valid", signer, token, ""},
+		{"forged claims", signer, forged, CodeInvalidToken},
+		{"other secret", NewSessionSigner("another secret", time.Hour), token, CodeInvalidToken},
+		{"random secret", NewSessionSigner("", time.Hour), token, CodeInvalidToken},
+		{"no signature", signer, encoded, CodeInvalidToken},
+		{"not base64", signer, "!!!." + signature, CodeInvalidToken},
+		{"expired", signer, expired, CodeTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.signer.Verify(tt.token)
+			if tt.wantCode != "" {
+				var protocolErr *ProtocolError
+				if !errors.As(err, &protocolErr) || protocolErr.Code != tt.wantCode {
+					t.Errorf("Verify error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if claims.GameID != "g1" || claims.PlayerID != "p1" || claims.Username != "alice" || claims.Seat != 2 {
+				t.Errorf("claims = %+v, want alice in seat 2 of g1", claims)
+			}
+		})
+	}
+}
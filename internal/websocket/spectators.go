@@ -0,0 +1,139 @@
+package websocket
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// spectators tracks who is watching which game. A client watches at most
+// one game at a time; watching another moves it there.
+type spectators struct {
+	mu       sync.Mutex
+	max      int
+	games    map[string]map[*Client]bool
+	watching map[*Client]string
+}
+
+func newSpectators(max int) *spectators {
+	return &spectators{
+		max:      max,
+		games:    make(map[string]map[*Client]bool),
+		watching: make(map[*Client]string),
+	}
+}
+
+// add makes the client a spectator of the game and returns the new number of
+// spectators there, and the game it stopped watching, if any.
+func (s *spectators) add(gameID string, client *Client) (int, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.watching[client]
+	if previous == gameID {
+		return len(s.games[gameID]), "", nil
+	}
+	if s.max > 0 && len(s.games[gameID]) >= s.max {
+		return 0, "", &ProtocolError{
+			Code:    CodeSpectatorLimit,
+			Message: "this game has as many spectators as it can take",
+			Details: map[string]interface{}{"max_spectators": s.max},
+		}
+	}
+
+	if previous != "" {
+		s.removeLocked(previous, client)
+	}
+	if s.games[gameID] == nil {
+		s.games[gameID] = make(map[*Client]bool)
+	}
+	s.games[gameID][client] = true
+	s.watching[client] = gameID
+	return len(s.games[gameID]), previous, nil
+}
+
+// leave stops the client watching and returns the game it was watching, or
+// "" if it was not a spectator.
+func (s *spectators) leave(client *Client) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gameID := s.watching[client]
+	if gameID != "" {
+		s.removeLocked(gameID, client)
+	}
+	return gameID
+}
+
+func (s *spectators) removeLocked(gameID string, client *Client) {
+	delete(s.watching, client)
+	delete(s.games[gameID], client)
+	if len(s.games[gameID]) == 0 {
+		delete(s.games, gameID)
+	}
+}
+
+// list returns the spectators of a game.
+func (s *spectators) list(gameID string) []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make([]*Client, 0, len(s.games[gameID]))
+	for client := range s.games[gameID] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// gameOf returns the game the client is watching, or "".
+func (s *spectators) gameOf(client *Client) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watching[client]
+}
+
+func (s *spectators) count(gameID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.games[gameID])
+}
+
+// drop forgets every spectator of a game that has ended.
+func (s *spectators) drop(gameID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.games[gameID] {
+		delete(s.watching, client)
+	}
+	delete(s.games, gameID)
+}
+
+// shareLinkPath is the path of a link to watch a game, followed by the game
+// ID.
+const shareLinkPath = "/watch/"
+
+// shareLink returns the link that lets anyone watch a game. With no public
+// URL configured the link is relative to the site.
+func shareLink(publicURL, gameID string) string {
+	return strings.TrimRight(publicURL, "/") + shareLinkPath + gameID
+}
+
+// parseShareLink extracts the game ID from a share link. Links of the form
+// ".../watch/<id>" and "...?watch=<id>" are accepted.
+func parseShareLink(link string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return "", false
+	}
+	if id := u.Query().Get("watch"); id != "" {
+		return id, true
+	}
+
+	_, id, ok := strings.Cut(u.Path, shareLinkPath)
+	id = strings.Trim(id, "/")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
@@ -14,6 +14,7 @@ const (
 	StatusWaiting  GameStatus = "waiting"
 	StatusPlaying  GameStatus = "playing"
 	StatusFinished GameStatus = "finished"
+	StatusAbandoned GameStatus = "abandoned"
 )
 
 type GameResult string
@@ -24,10 +25,82 @@ const (
 	ResultDraw GameResult = "draw"
 )
 
+// Game modes. Only rated games count towards the leaderboard.
+const (
+	ModeRated    = "rated"
+	ModeCasual   = "casual"
+	ModePractice = "practice"
+)
+
+// Bot difficulties. Easier bots make more deliberate mistakes, and the hard
+// bot never leaves an immediate win open when it can help it.
+const (
+	BotEasy   = "easy"
+	BotMedium = "medium"
+	BotHard   = "hard"
+)
+
+// Rulesets. Under the swap rule the second player may take over the first
+// player's opening move and colour instead of replying. Balanced games start
+// from a random book opening and come in pairs, the second game played from
+// the same opening with the seats reversed. Arcade games give each player a
+// few power-up pieces. 3D games are Score Four on a 4x4 grid of pegs.
+const (
+	RulesetStandard = "standard"
+	RulesetSwap     = "swap"
+	RulesetBalanced = "balanced"
+	RulesetArcade   = "arcade"
+	Ruleset3D       = "3d"
+)
+
+// GameSettings are the options a game was created with.
+type GameSettings struct {
+	// StartPosition is a move string ("4453") or FEN-style board to start
+	// from instead of the empty board.
+	StartPosition string    `json:"start_position,omitempty" bson:"start_position,omitempty"`
+	Handicap      *Handicap `json:"handicap,omitempty" bson:"handicap,omitempty"`
+	// FirstTurn is the seat that moves first; 0 leaves it to the position.
+	FirstTurn int `json:"first_turn,omitempty" bson:"first_turn,omitempty"`
+	// Mode is ModeRated, ModeCasual or ModePractice; empty means rated.
+	Mode string `json:"mode,omitempty" bson:"mode,omitempty"`
+	// Ruleset is RulesetStandard, RulesetSwap, RulesetBalanced,
+	// RulesetArcade or Ruleset3D; empty means standard.
+	Ruleset string `json:"ruleset,omitempty" bson:"ruleset,omitempty"`
+	// Opening is the ID of the book opening a balanced game starts from.
+	Opening string `json:"opening,omitempty" bson:"opening,omitempty"`
+	// MatchID groups the two games of a balanced mini-match, and MatchGame
+	// is 1 or 2.
+	MatchID   string `json:"match_id,omitempty" bson:"match_id,omitempty"`
+	MatchGame int    `json:"match_game,omitempty" bson:"match_game,omitempty"`
+	// Seats is the number of players, 3 or 4 for a free-for-all on a wider
+	// board; 0 means the usual two.
+	Seats int `json:"seats,omitempty" bson:"seats,omitempty"`
+	// Teams makes a four-seat game two teams of two. Teammates share a
+	// colour and take turns at moving for it.
+	Teams bool `json:"teams,omitempty" bson:"teams,omitempty"`
+	// Private marks a game started from an invite, which the lobby does not
+	// list.
+	Private bool `json:"private,omitempty" bson:"private,omitempty"`
+	// TurnSeconds is the time a player has for each move; 0 means the
+	// server's default.
+	TurnSeconds int `json:"turn_seconds,omitempty" bson:"turn_seconds,omitempty"`
+	// BotLevel is the difficulty of the bot in a bot game: BotEasy,
+	// BotMedium or BotHard; empty means medium.
+	BotLevel string `json:"bot_level,omitempty" bson:"bot_level,omitempty"`
+}
+
+// Handicap gives one seat extra pieces before the first move.
+type Handicap struct {
+	Seat    int   `json:"seat" bson:"seat"`
+	Columns []int `json:"columns" bson:"columns"` // 0-indexed
+}
+
 type Game struct {
 	ID          string      `json:"id" bson:"_id"`
 	Player1     *Player     `json:"player1" bson:"player1"`
 	Player2     *Player     `json:"player2" bson:"player2"`
+	// Board is rows of cells, top row first. A 3D board has one row per
+	// height and one column per peg, pegs numbered across the grid.
 	Board       [][]int     `json:"board" bson:"board"`
 	CurrentTurn int         `json:"current_turn" bson:"current_turn"`
 	Status      GameStatus  `json:"status" bson:"status"`
@@ -36,12 +109,118 @@ type Game struct {
 	CreatedAt   time.Time   `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at" bson:"updated_at"`
 	FinishedAt  *time.Time  `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+	Moves       []Move      `json:"moves" bson:"moves"`
+	EndReason   string      `json:"end_reason,omitempty" bson:"end_reason,omitempty"`
+	Settings    GameSettings `json:"settings" bson:"settings"`
+	StartFEN    string      `json:"start_fen,omitempty" bson:"start_fen,omitempty"`
+	// TakebackRequest is the seat waiting on an answer to a takeback, or 0.
+	TakebackRequest int     `json:"takeback_request,omitempty" bson:"takeback_request,omitempty"`
+	TurnDeadline *time.Time `json:"turn_deadline,omitempty" bson:"turn_deadline,omitempty"`
+	// Seats lists the players of a free-for-all game in turn order; seat n
+	// is Seats[n-1]. Player1 and Player2 mirror the first two seats. Two
+	// player games leave it empty.
+	Seats []*Player `json:"seats,omitempty" bson:"seats,omitempty"`
+	// Placements ranks the players of a free-for-all game. Players knocked
+	// out by resigning or timing out are placed as they leave.
+	Placements []Placement `json:"placements,omitempty" bson:"placements,omitempty"`
+	// TurnSeat is the seat to move in a team game; CurrentTurn is then the
+	// colour that seat plays.
+	TurnSeat int `json:"turn_seat,omitempty" bson:"turn_seat,omitempty"`
+	// WinningTeam is the colour of the team that won a team game, or 0.
+	WinningTeam int `json:"winning_team,omitempty" bson:"winning_team,omitempty"`
+	// Inventory holds the power-ups each seat has left in an arcade game,
+	// indexed by seat - 1.
+	Inventory []Inventory `json:"inventory,omitempty" bson:"inventory,omitempty"`
+}
+
+// Inventory counts the power-ups a player has left by move type.
+type Inventory map[string]int
+
+// CellChange is a board cell that a move changed, with its new value.
+type CellChange struct {
+	Row    int `json:"row" bson:"row"`
+	Column int `json:"column" bson:"column"`
+	Value  int `json:"value" bson:"value"`
+}
+
+// GameRecord is a finished game as stored in the games collection.
+type GameRecord struct {
+	ID              string     `json:"id" bson:"_id"`
+	Player1ID       string     `json:"player1_id" bson:"player1_id"`
+	Player1Username string     `json:"player1_username" bson:"player1_username"`
+	Player2ID       string     `json:"player2_id" bson:"player2_id"`
+	Player2Username string     `json:"player2_username" bson:"player2_username"`
+	WinnerID        string     `json:"winner_id,omitempty" bson:"winner_id"`
+	WinnerUsername  string     `json:"winner_username,omitempty" bson:"winner_username"`
+	IsBot           bool       `json:"is_bot" bson:"is_bot"`
+	Status          GameStatus `json:"status" bson:"status"`
+	EndReason       string     `json:"end_reason,omitempty" bson:"end_reason"`
+	Moves           []Move     `json:"moves" bson:"moves"`
+	StartFEN        string     `json:"start_fen,omitempty" bson:"start_fen,omitempty"`
+	Ruleset         string     `json:"ruleset,omitempty" bson:"ruleset,omitempty"`
+	Opening         string     `json:"opening,omitempty" bson:"opening,omitempty"`
+	MatchID         string     `json:"match_id,omitempty" bson:"match_id,omitempty"`
+	Seats           []Player    `json:"seats,omitempty" bson:"seats,omitempty"`
+	Placements      []Placement `json:"placements,omitempty" bson:"placements,omitempty"`
+	Teams           bool        `json:"teams,omitempty" bson:"teams,omitempty"`
+	TimeControl     string     `json:"time_control,omitempty" bson:"time_control,omitempty"`
+	Source          string     `json:"source,omitempty" bson:"source,omitempty"`
+	CreatedAt       time.Time  `json:"created_at" bson:"created_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty" bson:"finished_at"`
+}
+
+// SourceImport marks a game record loaded from a file rather than played
+// on this server. Imported games are kept out of head-to-head scores and
+// opening stats.
+const SourceImport = "import"
+
+// Placement is where a player finished in a free-for-all game. Players
+// still in when the game ends on the board share a place.
+type Placement struct {
+	Seat     int    `json:"seat" bson:"seat"`
+	PlayerID string `json:"player_id" bson:"player_id"`
+	Username string `json:"username" bson:"username"`
+	Place    int    `json:"place" bson:"place"`
+}
+
+// OpeningStats totals the results of games started from one book opening.
+type OpeningStats struct {
+	Opening     string `json:"opening" bson:"_id"`
+	Games       int    `json:"games" bson:"games"`
+	Player1Wins int    `json:"player1_wins" bson:"player1_wins"`
+	Player2Wins int    `json:"player2_wins" bson:"player2_wins"`
+	Draws       int    `json:"draws" bson:"draws"`
 }
 
+// Move types. A plain move has an empty type.
+const (
+	MoveTakeback   = "takeback"
+	MoveSwap       = "swap"
+	MoveEliminated = "eliminated"
+
+	// Power-ups. An anvil clears its column and lands at the bottom, a bomb
+	// lands and blows up itself and its neighbours, and a wall fills a cell
+	// with a neutral block.
+	MoveAnvil = "anvil"
+	MoveBomb  = "bomb"
+	MoveWall  = "wall"
+)
+
 type Move struct {
 	GameID string `json:"game_id" bson:"game_id"`
 	Column int    `json:"column" bson:"column"`
+	Row    int    `json:"row" bson:"row"`
 	Player int    `json:"player" bson:"player"`
+	Comment string `json:"comment,omitempty" bson:"comment,omitempty"`
+	// Seat is the teammate who moved in a team game, where Player is the
+	// team colour.
+	Seat int `json:"seat,omitempty" bson:"seat,omitempty"`
+	// Type is empty for a dropped piece; a takeback entry removes the piece
+	// at Column/Row that Player had dropped, and a swap entry records seat 2
+	// taking over the opening move, after which the seats are exchanged.
+	Type string `json:"type,omitempty" bson:"type,omitempty"`
+	// Changes lists every cell a power-up changed.
+	Changes []CellChange `json:"changes,omitempty" bson:"changes,omitempty"`
 }
 
 type GameEvent struct {
@@ -51,6 +230,69 @@ type GameEvent struct {
 	Timestamp time.Time   `json:"timestamp" bson:"timestamp"`
 }
 
+// Emotes are the quick reactions players can send during a game.
+const (
+	EmoteGoodMove = "good_move"
+	EmoteOops     = "oops"
+	EmoteGG       = "gg"
+	EmoteThinking = "thinking"
+)
+
+// Emotes lists every emote in the order clients show them.
+var Emotes = []string{EmoteGoodMove, EmoteOops, EmoteGG, EmoteThinking}
+
+// EmoteEvent is the analytics event data of a sent emote.
+type EmoteEvent struct {
+	Emote    string `json:"emote" bson:"emote"`
+	Username string `json:"username" bson:"username"`
+	Seat     int    `json:"seat" bson:"seat"`
+}
+
+// EmoteStats counts how often an emote has been sent.
+type EmoteStats struct {
+	Emote string `json:"emote" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+// Chat channels. Players talk to each other, and spectators to each other;
+// in a team game each team also has a channel of its own.
+const (
+	ChatPlayers    = "players"
+	ChatSpectators = "spectators"
+	ChatTeam       = "team"
+)
+
+// ChatMessage is a chat message as logged with its game for moderation.
+// Text is what was delivered; Original is what was typed, kept only when
+// the word filter changed it.
+type ChatMessage struct {
+	GameID   string    `json:"game_id" bson:"game_id"`
+	Channel  string    `json:"channel" bson:"channel"`
+	From     string    `json:"from" bson:"from"`
+	Seat     int       `json:"seat,omitempty" bson:"seat,omitempty"`
+	Text     string    `json:"text" bson:"text"`
+	Original string    `json:"original,omitempty" bson:"original,omitempty"`
+	SentAt   time.Time `json:"sent_at" bson:"sent_at"`
+}
+
+// HeadToHead is the running score between two players over their finished
+// games, with wins keyed by username.
+type HeadToHead struct {
+	Wins  map[string]int `json:"wins"`
+	Draws int            `json:"draws"`
+	Games int            `json:"games"`
+}
+
+// TeamLeaderboardEntry is the record of a fixed pair of teammates.
+type TeamLeaderboardEntry struct {
+	Team       string   `json:"team" bson:"team"`
+	Members    []string `json:"members" bson:"members"`
+	Wins       int      `json:"wins" bson:"wins"`
+	Losses     int      `json:"losses" bson:"losses"`
+	Draws      int      `json:"draws" bson:"draws"`
+	TotalGames int      `json:"total_games" bson:"total_games"`
+}
+
 type LeaderboardEntry struct {
 	Username   string `json:"username" bson:"username"`
 	Wins       int    `json:"wins" bson:"wins"`